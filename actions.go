@@ -1,5 +1,7 @@
 package brainy
 
+import "time"
+
 type sendActionEvent struct {
 	SourceEvent Event
 }
@@ -18,3 +20,139 @@ func Send(event Event) Actioner {
 		SourceEvent: event,
 	}
 }
+
+type sendAfterAction struct {
+	Delay       time.Duration
+	SourceEvent Event
+}
+
+func (a sendAfterAction) run(Context, Event) error {
+	return nil
+}
+
+// SendAfter function creates a declarative action, that will send the event to the state machine itself
+// once delay has elapsed, as measured by the clock of the state machine. See WithClock.
+//
+// An event scheduled by an entry action is cancelled when the state node that is entered is exited,
+// which allows to describe timeouts. All scheduled events are cancelled when the state machine is stopped.
+func SendAfter(delay time.Duration, event Event) Actioner {
+	return sendAfterAction{
+		Delay:       delay,
+		SourceEvent: event,
+	}
+}
+
+// SpawnFn returns the configuration of a child state machine to spawn.
+// It takes the context of the parent state machine and the event that lead to the spawn action being run.
+//
+// A new configuration must be returned each time, as state nodes can not be shared between state machines.
+type SpawnFn func(c Context, e Event) (StateNode, error)
+
+// SpawnAssignFn receives the spawned child state machine so that it can be stored
+// in the context of the parent state machine.
+type SpawnAssignFn func(c Context, e Event, child *Machine)
+
+// ChildSelector returns the child state machine to send an event to.
+// Children are usually retrieved from the context of the parent state machine.
+type ChildSelector func(c Context, e Event) *Machine
+
+type spawnAction struct {
+	Create SpawnFn
+	Assign SpawnAssignFn
+}
+
+func (a spawnAction) run(Context, Event) error {
+	return nil
+}
+
+// Spawn function creates a declarative action, that will create a child state machine from
+// the configuration returned by create, and give it to assign so that it can be stored in the context.
+//
+// A child spawned by an entry action belongs to the state node that is entered, and is stopped when this state node is exited.
+// Other children belong to the state machine itself.
+// All children are stopped when their parent state machine is stopped.
+func Spawn(create SpawnFn, assign SpawnAssignFn) Actioner {
+	return spawnAction{
+		Create: create,
+		Assign: assign,
+	}
+}
+
+type sendToAction struct {
+	To          ChildSelector
+	SourceEvent Event
+}
+
+func (a sendToAction) run(Context, Event) error {
+	return nil
+}
+
+// SendTo function creates a declarative action, that will send the event given as parameter
+// to the child state machine returned by to.
+//
+// The event is delivered once the state machine has finished processing the event that lead to the action.
+func SendTo(to ChildSelector, event Event) Actioner {
+	return sendToAction{
+		To:          to,
+		SourceEvent: event,
+	}
+}
+
+type forwardToAction struct {
+	To ChildSelector
+}
+
+func (a forwardToAction) run(Context, Event) error {
+	return nil
+}
+
+// ForwardTo function creates a declarative action, that will send the event that lead to the action
+// to the child state machine returned by to.
+func ForwardTo(to ChildSelector) Actioner {
+	return forwardToAction{
+		To: to,
+	}
+}
+
+type sendParentAction struct {
+	SourceEvent Event
+}
+
+func (a sendParentAction) run(Context, Event) error {
+	return nil
+}
+
+// SendParent function creates a declarative action, that will send the event given as parameter
+// to the parent state machine, if the state machine has been spawned.
+//
+// The event is delivered once the state machine has finished processing the event that lead to the action.
+func SendParent(event Event) Actioner {
+	return sendParentAction{
+		SourceEvent: event,
+	}
+}
+
+// AssignFn returns the new context of the state machine. It takes the current context and the event
+// that lead to the action being run.
+//
+// The context given as parameter should not be mutated: a new value should be returned instead,
+// so that Definition.Transition can compute context changes without side effects.
+type AssignFn func(c Context, e Event) Context
+
+type assignAction struct {
+	Fn AssignFn
+}
+
+func (a assignAction) run(Context, Event) error {
+	return nil
+}
+
+// Assign function creates a declarative action, that will replace the context of the state machine
+// by the value returned by fn.
+//
+// The actions that run after an Assign action receive the new context.
+func Assign(fn AssignFn) Actioner {
+	return assignAction{
+		Fn: fn,
+	}
+}
@@ -0,0 +1,99 @@
+package brainy
+
+import "errors"
+
+// ErrNoParentMachine is returned by a SendParent action when the state machine has not been spawned by another one.
+var ErrNoParentMachine = errors.New("state machine does not have a parent")
+
+// ErrNoChildMachine is returned by SendTo and ForwardTo actions when their ChildSelector did not return a state machine.
+var ErrNoChildMachine = errors.New("no child state machine to send the event to")
+
+// message is an event waiting to be delivered to another state machine.
+type message struct {
+	to    *Machine
+	event Event
+}
+
+func withParent(parent *Machine) MachineOption {
+	return func(machine *Machine) {
+		machine.parent = parent
+	}
+}
+
+// spawn creates a child state machine and registers it as belonging to owner.
+// When owner is nil, the child belongs to the state machine itself.
+func (machine *Machine) spawn(action spawnAction, owner *StateNode, c Context, e Event) error {
+	config, err := action.Create(c, e)
+	if err != nil {
+		return err
+	}
+
+	child, err := newMachine(config, withParent(machine), WithClock(machine.clock))
+	if err != nil {
+		return err
+	}
+
+	machine.children[child] = owner
+	// Messages sent by the child during its initial transition are delivered once
+	// the parent has been unlocked, as they might target the parent itself.
+	machine.outbox = append(machine.outbox, child.lockAndTakeOutbox()...)
+
+	if assign := action.Assign; assign != nil {
+		assign(c, e, child)
+	}
+
+	return nil
+}
+
+// stopChildren stops the children state machines that belong to owner.
+func (machine *Machine) stopChildren(owner *StateNode) {
+	for child, childOwner := range machine.children {
+		if childOwner != owner {
+			continue
+		}
+
+		child.Stop()
+		delete(machine.children, child)
+	}
+}
+
+func (machine *Machine) stopAllChildren() {
+	for child := range machine.children {
+		child.Stop()
+		delete(machine.children, child)
+	}
+}
+
+func (machine *Machine) addToOutbox(to *Machine, event Event) {
+	machine.outbox = append(machine.outbox, message{
+		to:    to,
+		event: event,
+	})
+}
+
+func (machine *Machine) takeOutbox() []message {
+	messages := machine.outbox
+	machine.outbox = nil
+
+	return messages
+}
+
+func (machine *Machine) lockAndTakeOutbox() []message {
+	if !machine.disableLocking {
+		machine.lock.Lock()
+		defer machine.lock.Unlock()
+	}
+
+	return machine.takeOutbox()
+}
+
+// deliverMessages sends messages to their recipients.
+// It must be called once the sending state machine has been unlocked, as recipients can send events back to it.
+//
+// Errors returned by recipients are not reported, as the event that lead to the messages
+// has already been processed.
+func deliverMessages(messages []message) {
+	for _, messageToDeliver := range messages {
+		messageToDeliver.to.Send(messageToDeliver.event)
+	}
+}
@@ -0,0 +1,141 @@
+package brainy_test
+
+import (
+	"testing"
+
+	"github.com/Devessier/brainy"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	SupervisingState brainy.StateType = "supervising"
+	FinishedState    brainy.StateType = "finished"
+	WaitingState     brainy.StateType = "waiting"
+	PongedState      brainy.StateType = "ponged"
+
+	PingEvent    brainy.EventType = "PING"
+	PongEvent    brainy.EventType = "PONG"
+	ForwardEvent brainy.EventType = "FORWARD"
+)
+
+type SessionContext struct {
+	Request *brainy.Machine
+}
+
+func selectRequest(c brainy.Context, e brainy.Event) *brainy.Machine {
+	return c.(*SessionContext).Request
+}
+
+func newRequestMachine(c brainy.Context, e brainy.Event) (brainy.StateNode, error) {
+	return brainy.StateNode{
+		Initial: WaitingState,
+
+		States: brainy.StateNodes{
+			WaitingState: &brainy.StateNode{
+				On: brainy.Events{
+					PingEvent:    PongedState,
+					ForwardEvent: PongedState,
+				},
+			},
+
+			PongedState: &brainy.StateNode{
+				OnEntry: brainy.Actions{
+					brainy.SendParent(PongEvent),
+				},
+			},
+		},
+	}, nil
+}
+
+func newSessionMachine(t *testing.T, sessionContext *SessionContext) *brainy.Machine {
+	stateMachine, err := brainy.NewMachine(brainy.StateNode{
+		Context: sessionContext,
+
+		Initial: SupervisingState,
+
+		States: brainy.StateNodes{
+			SupervisingState: &brainy.StateNode{
+				OnEntry: brainy.Actions{
+					brainy.Spawn(newRequestMachine, func(c brainy.Context, e brainy.Event, child *brainy.Machine) {
+						c.(*SessionContext).Request = child
+					}),
+				},
+
+				On: brainy.Events{
+					PingEvent: brainy.Transition{
+						Actions: brainy.Actions{
+							brainy.SendTo(selectRequest, PingEvent),
+						},
+					},
+					ForwardEvent: brainy.Transition{
+						Actions: brainy.Actions{
+							brainy.ForwardTo(selectRequest),
+						},
+					},
+					PongEvent: FinishedState,
+				},
+			},
+
+			FinishedState: &brainy.StateNode{},
+		},
+	})
+	assert.NoError(t, err)
+
+	return stateMachine
+}
+
+func TestParentAndChildMachinesExchangeEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	sessionContext := &SessionContext{}
+	sessionMachine := newSessionMachine(t, sessionContext)
+
+	requestMachine := sessionContext.Request
+	assert.NotNil(requestMachine)
+	assert.True(requestMachine.Current().Matches(WaitingState))
+
+	_, err := sessionMachine.Send(PingEvent)
+	assert.NoError(err)
+	assert.True(requestMachine.Current().Matches(PongedState))
+	assert.True(sessionMachine.Current().Matches(FinishedState))
+
+	// The child has been stopped when its owning state node was exited.
+	_, err = requestMachine.Send(PingEvent)
+	assert.ErrorIs(err, brainy.ErrMachineStopped)
+}
+
+func TestForwardToSendsTheCurrentEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	sessionContext := &SessionContext{}
+	sessionMachine := newSessionMachine(t, sessionContext)
+
+	_, err := sessionMachine.Send(ForwardEvent)
+	assert.NoError(err)
+	assert.True(sessionMachine.Current().Matches(FinishedState))
+}
+
+func TestStoppingParentStopsChildren(t *testing.T) {
+	assert := assert.New(t)
+
+	sessionContext := &SessionContext{}
+	sessionMachine := newSessionMachine(t, sessionContext)
+
+	sessionMachine.Stop()
+
+	_, err := sessionContext.Request.Send(PingEvent)
+	assert.ErrorIs(err, brainy.ErrMachineStopped)
+}
+
+func TestSendParentRequiresAParent(t *testing.T) {
+	assert := assert.New(t)
+
+	requestConfig, err := newRequestMachine(nil, nil)
+	assert.NoError(err)
+
+	requestMachine, err := brainy.NewMachine(requestConfig)
+	assert.NoError(err)
+
+	_, err = requestMachine.Send(PingEvent)
+	assert.ErrorIs(err, brainy.ErrNoParentMachine)
+}
@@ -0,0 +1,105 @@
+// Package brainytest provides helpers to test brainy state machines: a builder of state machines
+// running on a FakeClock, assertions on their states, and a Recorder of the actions they run.
+//
+//  machine, clock := brainytest.NewMachine(t, config)
+//
+//  brainytest.AssertTransition(t, machine, StartEvent, "waiting")
+//  clock.AdvanceTime(5 * time.Second)
+//  brainytest.AssertState(t, machine, "timed_out")
+package brainytest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Devessier/brainy"
+)
+
+// NewMachine builds a state machine from the configuration, running on a FakeClock set to the Unix epoch, in UTC.
+// The test fails immediately if the configuration is invalid, and the state machine is stopped when the test ends.
+func NewMachine(t testing.TB, config brainy.StateNode, options ...brainy.MachineOption) (*brainy.Machine, *FakeClock) {
+	t.Helper()
+
+	clock := NewFakeClock(time.Unix(0, 0).UTC())
+
+	machine, err := brainy.NewMachine(config, append([]brainy.MachineOption{brainy.WithClock(clock)}, options...)...)
+	if err != nil {
+		t.Fatalf("invalid state machine configuration: %v", err)
+	}
+	t.Cleanup(machine.Stop)
+
+	return machine, clock
+}
+
+// AssertState checks that the state value of the state machine is exactly stateValue, such as `a.b`.
+func AssertState(t testing.TB, machine *brainy.Machine, stateValue string) bool {
+	t.Helper()
+
+	return assertStateValue(t, machine.State(), stateValue)
+}
+
+// AssertTransition sends the event to the state machine, and checks that it is accepted and that
+// the state value it leads to is exactly stateValue.
+func AssertTransition(t testing.TB, machine *brainy.Machine, event brainy.Event, stateValue string) bool {
+	t.Helper()
+
+	state, err := machine.Send(event)
+	if err != nil {
+		t.Errorf("unexpected error while sending event: %v", err)
+
+		return false
+	}
+
+	return assertStateValue(t, state, stateValue)
+}
+
+func assertStateValue(t testing.TB, state brainy.State, stateValue string) bool {
+	t.Helper()
+
+	expectedValue, err := brainy.ParseStateValue(stateValue)
+	if err != nil {
+		t.Errorf("invalid expected state value: %v", err)
+
+		return false
+	}
+
+	if actual, expected := state.Value.String(), expectedValue.String(); actual != expected {
+		t.Errorf("expected state %s, got %s", expected, actual)
+
+		return false
+	}
+
+	return true
+}
+
+// ExpectActions checks that the calls recorded by the recorder are exactly the given names, in order,
+// and then resets the recorder, so that following expectations only consider new calls.
+func ExpectActions(t testing.TB, recorder *Recorder, names ...string) bool {
+	t.Helper()
+
+	actualNames := recorder.Names()
+	recorder.Reset()
+
+	if !equalNames(actualNames, names) {
+		t.Errorf("expected actions [%s], got [%s]", strings.Join(names, ", "), strings.Join(actualNames, ", "))
+
+		return false
+	}
+
+	return true
+}
+
+func equalNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for index := range a {
+		if a[index] != b[index] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,149 @@
+package brainytest_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Devessier/brainy"
+	"github.com/Devessier/brainy/brainytest"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	IdleState     brainy.StateType = "idle"
+	WaitingState  brainy.StateType = "waiting"
+	TimedOutState brainy.StateType = "timed_out"
+	DoneState     brainy.StateType = "done"
+
+	StartEvent   brainy.EventType = "START"
+	TimeoutEvent brainy.EventType = "TIMEOUT"
+	RespondEvent brainy.EventType = "RESPOND"
+)
+
+func timeoutMachineConfig(recorder *brainytest.Recorder) brainy.StateNode {
+	return brainy.StateNode{
+		Initial: IdleState,
+
+		States: brainy.StateNodes{
+			IdleState: &brainy.StateNode{
+				On: brainy.Events{
+					StartEvent: WaitingState,
+				},
+			},
+
+			WaitingState: &brainy.StateNode{
+				OnEntry: brainy.Actions{
+					recorder.Action("startWaiting"),
+					brainy.SendAfter(5*time.Second, TimeoutEvent),
+				},
+
+				On: brainy.Events{
+					TimeoutEvent: brainy.Transition{
+						Target:  TimedOutState,
+						Actions: brainy.Actions{recorder.Action("notifyTimeout")},
+					},
+					RespondEvent: brainy.Transition{
+						Target: DoneState,
+						Cond:   recorder.Cond("isValidResponse", true),
+					},
+				},
+			},
+
+			TimedOutState: &brainy.StateNode{},
+
+			DoneState: &brainy.StateNode{},
+		},
+	}
+}
+
+func TestDelayedEventsAreSentWhenTimeIsAdvanced(t *testing.T) {
+	recorder := brainytest.NewRecorder()
+	machine, clock := brainytest.NewMachine(t, timeoutMachineConfig(recorder))
+
+	brainytest.AssertState(t, machine, "idle")
+	brainytest.AssertTransition(t, machine, StartEvent, "waiting")
+	brainytest.ExpectActions(t, recorder, "startWaiting")
+
+	clock.AdvanceTime(4 * time.Second)
+	brainytest.AssertState(t, machine, "waiting")
+	brainytest.ExpectActions(t, recorder)
+
+	clock.AdvanceTime(time.Second)
+	brainytest.AssertState(t, machine, "timed_out")
+	brainytest.ExpectActions(t, recorder, "notifyTimeout")
+}
+
+func TestDelayedEventsAreCancelledWhenStateNodeIsExited(t *testing.T) {
+	recorder := brainytest.NewRecorder()
+	machine, clock := brainytest.NewMachine(t, timeoutMachineConfig(recorder))
+
+	brainytest.AssertTransition(t, machine, StartEvent, "waiting")
+	brainytest.AssertTransition(t, machine, RespondEvent, "done")
+	brainytest.ExpectActions(t, recorder, "startWaiting", "isValidResponse")
+
+	clock.AdvanceTime(time.Minute)
+	brainytest.AssertState(t, machine, "done")
+	brainytest.ExpectActions(t, recorder)
+}
+
+func TestFakeClockCallsDueFunctionsInOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	start := time.Unix(0, 0)
+	clock := brainytest.NewFakeClock(start)
+	calls := make([]string, 0)
+
+	clock.AfterFunc(2*time.Second, func() {
+		calls = append(calls, fmt.Sprintf("second at %s", clock.Now().Sub(start)))
+	})
+	clock.AfterFunc(time.Second, func() {
+		calls = append(calls, fmt.Sprintf("first at %s", clock.Now().Sub(start)))
+
+		clock.AfterFunc(500*time.Millisecond, func() {
+			calls = append(calls, fmt.Sprintf("nested at %s", clock.Now().Sub(start)))
+		})
+	})
+	stoppedTimer := clock.AfterFunc(time.Second, func() {
+		calls = append(calls, "stopped")
+	})
+
+	assert.True(stoppedTimer.Stop())
+	assert.False(stoppedTimer.Stop())
+
+	clock.AdvanceTime(3 * time.Second)
+
+	assert.Equal([]string{"first at 1s", "nested at 1.5s", "second at 2s"}, calls)
+	assert.Equal(start.Add(3*time.Second), clock.Now())
+}
+
+// failureRecorder records the failures reported by assertions instead of failing the test.
+type failureRecorder struct {
+	testing.TB
+
+	failures []string
+}
+
+func (t *failureRecorder) Helper() {}
+
+func (t *failureRecorder) Errorf(format string, args ...interface{}) {
+	t.failures = append(t.failures, fmt.Sprintf(format, args...))
+}
+
+func TestAssertionsReportFailures(t *testing.T) {
+	assert := assert.New(t)
+
+	recorder := brainytest.NewRecorder()
+	machine, _ := brainytest.NewMachine(t, timeoutMachineConfig(recorder))
+	failures := &failureRecorder{TB: t}
+
+	assert.False(brainytest.AssertState(failures, machine, "waiting"))
+	assert.False(brainytest.AssertTransition(failures, machine, StartEvent, "done"))
+	assert.False(brainytest.ExpectActions(failures, recorder, "notifyTimeout"))
+
+	assert.Equal([]string{
+		"expected state waiting, got idle",
+		"expected state done, got waiting",
+		"expected actions [notifyTimeout], got [startWaiting]",
+	}, failures.failures)
+}
@@ -0,0 +1,124 @@
+package brainytest
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Devessier/brainy"
+)
+
+// A FakeClock is a brainy.Clock whose time only changes when AdvanceTime is called.
+// Scheduled functions are called by AdvanceTime, in the goroutine calling it, in the order of their due time.
+//
+// A FakeClock is safe for concurrent use.
+type FakeClock struct {
+	lock          sync.Mutex
+	now           time.Time
+	timers        []*fakeTimer
+	nextTimerRank int
+}
+
+// NewFakeClock returns a clock whose current time is now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{
+		now: now,
+	}
+}
+
+type fakeTimer struct {
+	clock   *FakeClock
+	dueTime time.Time
+	// rank orders timers with the same due time by creation.
+	rank int
+	f    func()
+}
+
+// Now returns the current time of the clock.
+func (clock *FakeClock) Now() time.Time {
+	clock.lock.Lock()
+	defer clock.lock.Unlock()
+
+	return clock.now
+}
+
+// AfterFunc schedules f to be called once the time of the clock has been advanced by d.
+func (clock *FakeClock) AfterFunc(d time.Duration, f func()) brainy.Timer {
+	clock.lock.Lock()
+	defer clock.lock.Unlock()
+
+	timer := &fakeTimer{
+		clock:   clock,
+		dueTime: clock.now.Add(d),
+		rank:    clock.nextTimerRank,
+		f:       f,
+	}
+	clock.nextTimerRank++
+	clock.timers = append(clock.timers, timer)
+
+	return timer
+}
+
+// AdvanceTime moves the time of the clock forward by d, and calls the functions that become due,
+// including the ones they schedule within d.
+func (clock *FakeClock) AdvanceTime(d time.Duration) {
+	clock.lock.Lock()
+	targetTime := clock.now.Add(d)
+	clock.lock.Unlock()
+
+	for {
+		timer := clock.popDueTimer(targetTime)
+		if timer == nil {
+			break
+		}
+
+		timer.f()
+	}
+
+	clock.lock.Lock()
+	clock.now = targetTime
+	clock.lock.Unlock()
+}
+
+// popDueTimer removes and returns the first timer due before targetTime, and moves the time of the clock
+// to its due time.
+func (clock *FakeClock) popDueTimer(targetTime time.Time) *fakeTimer {
+	clock.lock.Lock()
+	defer clock.lock.Unlock()
+
+	sort.SliceStable(clock.timers, func(i, j int) bool {
+		if clock.timers[i].dueTime.Equal(clock.timers[j].dueTime) {
+			return clock.timers[i].rank < clock.timers[j].rank
+		}
+
+		return clock.timers[i].dueTime.Before(clock.timers[j].dueTime)
+	})
+
+	if len(clock.timers) == 0 || clock.timers[0].dueTime.After(targetTime) {
+		return nil
+	}
+
+	timer := clock.timers[0]
+	clock.timers = clock.timers[1:]
+	clock.now = timer.dueTime
+
+	return timer
+}
+
+// Stop unschedules the timer.
+func (timer *fakeTimer) Stop() bool {
+	clock := timer.clock
+
+	clock.lock.Lock()
+	defer clock.lock.Unlock()
+
+	for index, timerToCompare := range clock.timers {
+		if timerToCompare == timer {
+			clock.timers = append(clock.timers[:index:index], clock.timers[index+1:]...)
+
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,90 @@
+package brainytest
+
+import (
+	"sync"
+
+	"github.com/Devessier/brainy"
+)
+
+// A Call is a call of an action or a condition created by a Recorder.
+type Call struct {
+	Name    string
+	Context brainy.Context
+	Event   brainy.Event
+}
+
+// A Recorder creates actions and conditions that record their calls, in order.
+// It replaces mocks when the only thing to check is which actions ran.
+//
+// A Recorder is safe for concurrent use.
+type Recorder struct {
+	lock  sync.Mutex
+	calls []Call
+}
+
+// NewRecorder returns a recorder without any call.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+func (recorder *Recorder) record(name string, c brainy.Context, e brainy.Event) {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+
+	recorder.calls = append(recorder.calls, Call{
+		Name:    name,
+		Context: c,
+		Event:   e,
+	})
+}
+
+// Action returns an action that records its calls under name.
+func (recorder *Recorder) Action(name string) brainy.Actioner {
+	return recorder.FailingAction(name, nil)
+}
+
+// FailingAction returns an action that records its calls under name and returns err.
+func (recorder *Recorder) FailingAction(name string, err error) brainy.Actioner {
+	return brainy.ActionFn(func(c brainy.Context, e brainy.Event) error {
+		recorder.record(name, c, e)
+
+		return err
+	})
+}
+
+// Cond returns a condition that records its calls under name and returns result.
+func (recorder *Recorder) Cond(name string, result bool) brainy.Cond {
+	return func(c brainy.Context, e brainy.Event) bool {
+		recorder.record(name, c, e)
+
+		return result
+	}
+}
+
+// Calls returns the calls recorded since the recorder was created or reset.
+func (recorder *Recorder) Calls() []Call {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+
+	return append([]Call{}, recorder.calls...)
+}
+
+// Names returns the names of the recorded calls.
+func (recorder *Recorder) Names() []string {
+	calls := recorder.Calls()
+
+	names := make([]string, 0, len(calls))
+	for _, call := range calls {
+		names = append(names, call.Name)
+	}
+
+	return names
+}
+
+// Reset forgets the recorded calls.
+func (recorder *Recorder) Reset() {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+
+	recorder.calls = nil
+}
@@ -0,0 +1,459 @@
+// Package brainyvet defines an Analyzer that reports common misuses of brainy
+// in code that builds StateNode literals and runs state machines.
+//
+// It can be run with go vet thanks to the brainy-vet command:
+//
+//  go install github.com/Devessier/brainy/cmd/brainy-vet
+//  go vet -vettool=$(which brainy-vet) ./...
+//
+// The Analyzer reports:
+//
+// 1. targets of event handlers that reference a state that is not a sibling of the state node handling the event
+//
+// 2. initial states missing from the States of their state node
+//
+// 3. calls to Machine.Send from actions, guards and assigners, which run while the state machine is locked
+// and would deadlock
+//
+// 4. calls to Machine.Send whose error is ignored
+//
+// Only constant state types of literals are checked: states built at runtime are ignored.
+package brainyvet
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+const brainyPackagePath = "github.com/Devessier/brainy"
+
+// Analyzer reports common misuses of brainy.
+var Analyzer = &analysis.Analyzer{
+	Name:     "brainy",
+	Doc:      "report invalid brainy state nodes, Send calls from actions and ignored Send errors",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+// lockedCallbackTypes are the types of the functions that brainy calls while the state machine is locked.
+var lockedCallbackTypes = map[string]bool{
+	"Action":        true,
+	"AssignFn":      true,
+	"Cond":          true,
+	"SpawnAssignFn": true,
+	"ChildSelector": true,
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	// The brainy package deliberately ignores the errors of the events it delivers.
+	if pass.Pkg.Path() == brainyPackagePath {
+		return nil, nil
+	}
+
+	inspect := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+
+	checkStateNodes(pass, inspect)
+	checkLockedCallbacks(pass, inspect)
+	checkIgnoredSendErrors(pass, inspect)
+
+	return nil, nil
+}
+
+// isBrainyType returns whether t is the named type of the brainy package called name, or a pointer to it.
+func isBrainyType(t types.Type, name string) bool {
+	if pointer, ok := t.(*types.Pointer); ok {
+		t = pointer.Elem()
+	}
+
+	named, ok := t.(*types.Named)
+	if !ok {
+		return false
+	}
+
+	object := named.Obj()
+
+	return object.Pkg() != nil && object.Pkg().Path() == brainyPackagePath && object.Name() == name
+}
+
+// isMachineSend returns whether the call is a call to the Send method of a brainy Machine.
+func isMachineSend(pass *analysis.Pass, call *ast.CallExpr) bool {
+	selector, ok := call.Fun.(*ast.SelectorExpr)
+	if !ok || selector.Sel.Name != "Send" {
+		return false
+	}
+
+	selection, ok := pass.TypesInfo.Selections[selector]
+	if !ok || selection.Kind() != types.MethodVal {
+		return false
+	}
+
+	return isBrainyType(selection.Recv(), "Machine")
+}
+
+// constantString returns the value of the expression if it is a string constant.
+func constantString(pass *analysis.Pass, expr ast.Expr) (string, bool) {
+	typeAndValue, ok := pass.TypesInfo.Types[expr]
+	if !ok || typeAndValue.Value == nil || typeAndValue.Value.Kind() != constant.String {
+		return "", false
+	}
+
+	return constant.StringVal(typeAndValue.Value), true
+}
+
+// checkStateNodes checks the StateNode literals. The state nodes of a StateNodes literal are checked
+// against their siblings. StateNode literals whose address is not taken are considered as root state nodes,
+// whose targets are resolved from their own children; other literals are not checked as their siblings are unknown.
+func checkStateNodes(pass *analysis.Pass, inspect *inspector.Inspector) {
+	childrenStateNodes := make(map[*ast.CompositeLit]bool)
+
+	inspect.Preorder([]ast.Node{(*ast.CompositeLit)(nil)}, func(node ast.Node) {
+		literal := node.(*ast.CompositeLit)
+		if !isBrainyType(pass.TypesInfo.TypeOf(literal), "StateNodes") {
+			return
+		}
+
+		siblings, ok := stateNodesKeys(pass, literal)
+
+		for _, element := range literal.Elts {
+			keyValue, isKeyValue := element.(*ast.KeyValueExpr)
+			if !isKeyValue {
+				continue
+			}
+
+			stateNode := stateNodeLiteral(keyValue.Value)
+			if stateNode == nil {
+				continue
+			}
+
+			childrenStateNodes[stateNode] = true
+			checkStateNode(pass, stateNode, siblings, ok)
+		}
+	})
+
+	addressedStateNodes := make(map[*ast.CompositeLit]bool)
+
+	inspect.Preorder([]ast.Node{(*ast.UnaryExpr)(nil)}, func(node ast.Node) {
+		if unary := node.(*ast.UnaryExpr); unary.Op == token.AND {
+			if literal, ok := unary.X.(*ast.CompositeLit); ok {
+				addressedStateNodes[literal] = true
+			}
+		}
+	})
+
+	inspect.Preorder([]ast.Node{(*ast.CompositeLit)(nil)}, func(node ast.Node) {
+		literal := node.(*ast.CompositeLit)
+		if childrenStateNodes[literal] || addressedStateNodes[literal] || !isBrainyType(pass.TypesInfo.TypeOf(literal), "StateNode") {
+			return
+		}
+
+		children, ok := stateNodesKeys(pass, fieldValue(literal, "States"))
+		checkStateNode(pass, literal, children, ok)
+	})
+}
+
+// stateNodeLiteral returns the StateNode literal of a value of a StateNodes literal,
+// that is either `&StateNode{...}` or `{...}`.
+func stateNodeLiteral(expr ast.Expr) *ast.CompositeLit {
+	if unary, ok := expr.(*ast.UnaryExpr); ok && unary.Op == token.AND {
+		expr = unary.X
+	}
+
+	literal, _ := expr.(*ast.CompositeLit)
+
+	return literal
+}
+
+// stateNodesKeys returns the keys of a StateNodes literal, and whether they are all constant.
+// An absent literal has no keys.
+func stateNodesKeys(pass *analysis.Pass, expr ast.Expr) (map[string]bool, bool) {
+	keys := make(map[string]bool)
+	if expr == nil {
+		return keys, true
+	}
+
+	literal, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return nil, false
+	}
+
+	for _, element := range literal.Elts {
+		keyValue, isKeyValue := element.(*ast.KeyValueExpr)
+		if !isKeyValue {
+			return nil, false
+		}
+
+		key, isConstant := constantString(pass, keyValue.Key)
+		if !isConstant {
+			return nil, false
+		}
+
+		keys[key] = true
+	}
+
+	return keys, true
+}
+
+// fieldValue returns the value of the field of a struct literal, or nil if it is not set.
+func fieldValue(literal *ast.CompositeLit, name string) ast.Expr {
+	for _, element := range literal.Elts {
+		keyValue, ok := element.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+
+		if key, ok := keyValue.Key.(*ast.Ident); ok && key.Name == name {
+			return keyValue.Value
+		}
+	}
+
+	return nil
+}
+
+// checkStateNode checks the initial state of the state node, and the targets of its event handlers
+// and choice transitions against the states they are resolved from.
+func checkStateNode(pass *analysis.Pass, stateNode *ast.CompositeLit, resolvingStates map[string]bool, resolvingStatesAreKnown bool) {
+	if initial := fieldValue(stateNode, "Initial"); initial != nil {
+		children, childrenAreKnown := stateNodesKeys(pass, fieldValue(stateNode, "States"))
+		initialState, isConstant := constantString(pass, initial)
+
+		if childrenAreKnown && isConstant && initialState != "" && !children[initialState] {
+			pass.Reportf(initial.Pos(), "initial state %q is not one of the States of the state node", initialState)
+		}
+	}
+
+	if !resolvingStatesAreKnown {
+		return
+	}
+
+	for _, target := range transitionsTargets(pass, stateNode) {
+		checkTarget(pass, target, resolvingStates)
+	}
+}
+
+// transitionsTargets returns the target expressions of the event handlers, choice and junction transitions
+// of the state node.
+func transitionsTargets(pass *analysis.Pass, stateNode *ast.CompositeLit) []ast.Expr {
+	targets := make([]ast.Expr, 0)
+
+	if events, ok := fieldValue(stateNode, "On").(*ast.CompositeLit); ok {
+		for _, element := range events.Elts {
+			if keyValue, ok := element.(*ast.KeyValueExpr); ok {
+				targets = append(targets, transitionerTargets(pass, keyValue.Value)...)
+			}
+		}
+	}
+
+	for _, field := range []string{"Choice", "Junction"} {
+		if transitions := fieldValue(stateNode, field); transitions != nil {
+			targets = append(targets, transitionerTargets(pass, transitions)...)
+		}
+	}
+
+	return targets
+}
+
+// transitionerTargets returns the target expressions of a StateType, a Transition or a Transitions.
+func transitionerTargets(pass *analysis.Pass, expr ast.Expr) []ast.Expr {
+	transitionerType := pass.TypesInfo.TypeOf(expr)
+
+	switch {
+	case isBrainyType(transitionerType, "StateType"):
+		return []ast.Expr{expr}
+	case isBrainyType(transitionerType, "Transition"):
+		literal, ok := expr.(*ast.CompositeLit)
+		if !ok {
+			return nil
+		}
+
+		if target := fieldValue(literal, "Target"); target != nil && isBrainyType(pass.TypesInfo.TypeOf(target), "StateType") {
+			return []ast.Expr{target}
+		}
+	case isBrainyType(transitionerType, "Transitions"):
+		literal, ok := expr.(*ast.CompositeLit)
+		if !ok {
+			return nil
+		}
+
+		targets := make([]ast.Expr, 0)
+		for _, element := range literal.Elts {
+			if _, isTransition := element.(*ast.CompositeLit); isTransition {
+				targets = append(targets, transitionerTargets(pass, element)...)
+			}
+		}
+
+		return targets
+	}
+
+	return nil
+}
+
+// checkTarget reports a constant target whose first state is not one of the states it is resolved from.
+// Targets by ID and child targets are not checked.
+func checkTarget(pass *analysis.Pass, target ast.Expr, resolvingStates map[string]bool) {
+	targetID, isConstant := constantString(pass, target)
+	if !isConstant || targetID == "" || strings.HasPrefix(targetID, "#") || strings.HasPrefix(targetID, ".") {
+		return
+	}
+
+	firstState := strings.SplitN(targetID, ".", 2)[0]
+	if resolvingStates[firstState] {
+		return
+	}
+
+	states := make([]string, 0, len(resolvingStates))
+	for state := range resolvingStates {
+		states = append(states, state)
+	}
+	sort.Strings(states)
+
+	pass.Reportf(target.Pos(), "target %q does not reference a sibling state (expected one of: %s)", targetID, strings.Join(states, ", "))
+}
+
+// checkLockedCallbacks reports calls to Machine.Send in function literals given to brainy as actions,
+// guards and assigners.
+func checkLockedCallbacks(pass *analysis.Pass, inspect *inspector.Inspector) {
+	expectedTypes := expectedTypesOfFuncLits(pass, inspect)
+
+	inspect.Preorder([]ast.Node{(*ast.FuncLit)(nil)}, func(node ast.Node) {
+		funcLit := node.(*ast.FuncLit)
+		if !isLockedCallback(expectedTypes[funcLit]) {
+			return
+		}
+
+		ast.Inspect(funcLit.Body, func(node ast.Node) bool {
+			switch node := node.(type) {
+			case *ast.GoStmt:
+				// Sending from another goroutine does not deadlock.
+				return false
+			case *ast.CallExpr:
+				if isMachineSend(pass, node) {
+					pass.Reportf(node.Pos(), "Machine.Send called from a callback that runs while the state machine is locked; use the brainy.Send action instead")
+				}
+			}
+
+			return true
+		})
+	})
+}
+
+// isLockedCallback returns whether one of the types a function literal is given to brainy as
+// is one of the lockedCallbackTypes.
+func isLockedCallback(expectedTypes []types.Type) bool {
+	for _, callbackType := range expectedTypes {
+		named, ok := callbackType.(*types.Named)
+		if ok && lockedCallbackTypes[named.Obj().Name()] && isBrainyType(named, named.Obj().Name()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// expectedTypesOfFuncLits returns the types each function literal of the package is converted to,
+// looking at the parameters of the calls and the fields of the literals that reference it.
+// The files are walked once, whatever the number of function literals.
+func expectedTypesOfFuncLits(pass *analysis.Pass, inspect *inspector.Inspector) map[*ast.FuncLit][]types.Type {
+	expectedTypes := make(map[*ast.FuncLit][]types.Type)
+
+	nodeFilter := []ast.Node{
+		(*ast.CallExpr)(nil),
+		(*ast.KeyValueExpr)(nil),
+	}
+
+	inspect.Preorder(nodeFilter, func(node ast.Node) {
+		switch node := node.(type) {
+		case *ast.CallExpr:
+			for index, argument := range node.Args {
+				funcLit, ok := argument.(*ast.FuncLit)
+				if !ok {
+					continue
+				}
+
+				if argumentType := callArgumentType(pass, node, index); argumentType != nil {
+					expectedTypes[funcLit] = append(expectedTypes[funcLit], argumentType)
+				}
+			}
+		case *ast.KeyValueExpr:
+			funcLit, ok := node.Value.(*ast.FuncLit)
+			if !ok {
+				return
+			}
+
+			if key, ok := node.Key.(*ast.Ident); ok {
+				if field, ok := pass.TypesInfo.Uses[key].(*types.Var); ok {
+					expectedTypes[funcLit] = append(expectedTypes[funcLit], field.Type())
+				}
+			}
+		}
+	})
+
+	return expectedTypes
+}
+
+// callArgumentType returns the type of the parameter the argument at index is given to,
+// or the type the call converts it to.
+func callArgumentType(pass *analysis.Pass, call *ast.CallExpr, index int) types.Type {
+	if typeAndValue, ok := pass.TypesInfo.Types[call.Fun]; ok && typeAndValue.IsType() {
+		return typeAndValue.Type
+	}
+
+	signature, ok := pass.TypesInfo.TypeOf(call.Fun).(*types.Signature)
+	if !ok || signature.Params().Len() == 0 {
+		return nil
+	}
+
+	if index >= signature.Params().Len() {
+		index = signature.Params().Len() - 1
+	}
+
+	return signature.Params().At(index).Type()
+}
+
+// checkIgnoredSendErrors reports calls to Machine.Send whose results are discarded,
+// or whose error is assigned to the blank identifier.
+func checkIgnoredSendErrors(pass *analysis.Pass, inspect *inspector.Inspector) {
+	nodeFilter := []ast.Node{
+		(*ast.ExprStmt)(nil),
+		(*ast.AssignStmt)(nil),
+		(*ast.GoStmt)(nil),
+		(*ast.DeferStmt)(nil),
+	}
+
+	inspect.Preorder(nodeFilter, func(node ast.Node) {
+		switch statement := node.(type) {
+		case *ast.ExprStmt:
+			if call, ok := statement.X.(*ast.CallExpr); ok && isMachineSend(pass, call) {
+				pass.Reportf(call.Pos(), "error returned by Machine.Send is ignored")
+			}
+		case *ast.GoStmt:
+			if isMachineSend(pass, statement.Call) {
+				pass.Reportf(statement.Call.Pos(), "error returned by Machine.Send is ignored")
+			}
+		case *ast.DeferStmt:
+			if isMachineSend(pass, statement.Call) {
+				pass.Reportf(statement.Call.Pos(), "error returned by Machine.Send is ignored")
+			}
+		case *ast.AssignStmt:
+			if len(statement.Rhs) != 1 || len(statement.Lhs) != 2 {
+				return
+			}
+
+			call, ok := statement.Rhs[0].(*ast.CallExpr)
+			if !ok || !isMachineSend(pass, call) {
+				return
+			}
+
+			if errorIdent, ok := statement.Lhs[1].(*ast.Ident); ok && errorIdent.Name == "_" {
+				pass.Reportf(call.Pos(), "error returned by Machine.Send is ignored")
+			}
+		}
+	})
+}
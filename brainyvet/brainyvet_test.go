@@ -0,0 +1,12 @@
+package brainyvet_test
+
+import (
+	"testing"
+
+	"github.com/Devessier/brainy/brainyvet"
+	"golang.org/x/tools/go/analysis/analysistest"
+)
+
+func TestAnalyzer(t *testing.T) {
+	analysistest.Run(t, analysistest.TestData(), brainyvet.Analyzer, "a")
+}
@@ -0,0 +1,71 @@
+package a
+
+import "github.com/Devessier/brainy"
+
+const (
+	OnState  brainy.StateType = "on"
+	OffState brainy.StateType = "off"
+
+	ToggleEvent brainy.EventType = "TOGGLE"
+)
+
+var machine *brainy.Machine
+
+func config() brainy.StateNode {
+	return brainy.StateNode{
+		Initial: "unknown", // want `initial state "unknown" is not one of the States of the state node`
+
+		States: brainy.StateNodes{
+			OnState: &brainy.StateNode{
+				OnEntry: brainy.Actions{
+					brainy.ActionFn(func(c brainy.Context, e brainy.Event) error {
+						machine.Send(ToggleEvent) // want `Machine.Send called from a callback that runs while the state machine is locked` `error returned by Machine.Send is ignored`
+
+						go func() {
+							_, _ = machine.Send(ToggleEvent) // want `error returned by Machine.Send is ignored`
+						}()
+
+						return nil
+					}),
+				},
+
+				On: brainy.Events{
+					ToggleEvent: OffState,
+				},
+			},
+
+			OffState: &brainy.StateNode{
+				On: brainy.Events{
+					ToggleEvent: brainy.Transitions{
+						{
+							Cond: func(c brainy.Context, e brainy.Event) bool {
+								_, err := machine.Send(ToggleEvent) // want `Machine.Send called from a callback that runs while the state machine is locked`
+
+								return err == nil
+							},
+							Target: "unknown", // want `target "unknown" does not reference a sibling state \(expected one of: off, on\)`
+						},
+						{
+							Target: "#id.unknown",
+						},
+						{
+							Target: OnState,
+						},
+					},
+				},
+			},
+		},
+
+		On: brainy.Events{
+			ToggleEvent: brainy.Transition{
+				Target: "on.nested",
+			},
+		},
+	}
+}
+
+func send() error {
+	_, err := machine.Send(ToggleEvent)
+
+	return err
+}
@@ -0,0 +1,51 @@
+// Package brainy is a stub of the brainy package, that declares what the analyzer needs.
+package brainy
+
+type StateType string
+
+type EventType string
+
+type Context interface{}
+
+type Event interface{}
+
+type Actioner interface{}
+
+type Action func(Context, Event) error
+
+func ActionFn(fn Action) Actioner { return fn }
+
+type Actions []Actioner
+
+type Cond func(Context, Event) bool
+
+type Transitioner interface{}
+
+type Transition struct {
+	Cond    Cond
+	Target  StateType
+	Actions Actions
+}
+
+type Transitions []Transition
+
+type Events map[EventType]Transitioner
+
+type StateNodes map[StateType]*StateNode
+
+type StateNode struct {
+	Initial  StateType
+	States   StateNodes
+	OnEntry  Actions
+	On       Events
+	Choice   Transitions
+	Junction Transitions
+}
+
+type State struct{}
+
+type Machine struct{}
+
+func NewMachine(config StateNode) (*Machine, error) { return &Machine{}, nil }
+
+func (machine *Machine) Send(event Event) (State, error) { return State{}, nil }
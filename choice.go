@@ -0,0 +1,186 @@
+package brainy
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned when a choice or junction state node is invalid or can not pick a transition.
+var (
+	// ErrInvalidChoiceStateNode is returned when a choice or junction state node has other fields than its transitions,
+	// when it is both a choice and a junction, or when it is the initial state of its parent.
+	ErrInvalidChoiceStateNode = errors.New("invalid choice state node")
+	// ErrChoiceWithoutDefaultTransition is returned when the last transition of a choice or junction state node
+	// has a guard.
+	ErrChoiceWithoutDefaultTransition = errors.New("the last transition of a choice state node must not have a guard")
+	// ErrChoiceLoop is returned when the transitions picked by choice or junction state nodes go through
+	// the same state node twice.
+	ErrChoiceLoop = errors.New("choice state nodes loop")
+)
+
+// isChoice returns whether the state node is a choice pseudo-state, that is, whether it has choice transitions.
+func (s *StateNode) isChoice() bool {
+	return len(s.Choice) > 0
+}
+
+// isJunction returns whether the state node is a junction pseudo-state, that is, whether it has junction transitions.
+func (s *StateNode) isJunction() bool {
+	return len(s.Junction) > 0
+}
+
+// isPseudoState returns whether the state node is a choice or a junction, which are never active.
+func (s *StateNode) isPseudoState() bool {
+	return s.isChoice() || s.isJunction()
+}
+
+// pseudoStateTransitions returns the transitions of a choice or junction state node.
+func (s *StateNode) pseudoStateTransitions() Transitions {
+	if s.isJunction() {
+		return s.Junction
+	}
+
+	return s.Choice
+}
+
+// validateChoice checks that a choice or junction state node only has choice or junction transitions,
+// that they all have a target and that the last one is a default transition without guard.
+func (s *StateNode) validateChoice() error {
+	if s.Initial != NoneState && s.States[s.Initial] != nil && s.States[s.Initial].isPseudoState() {
+		return fmt.Errorf("%w: %s can not be the initial state of %s", ErrInvalidChoiceStateNode, s.States[s.Initial].id, s.id)
+	}
+
+	if !s.isPseudoState() {
+		return nil
+	}
+
+	if s.isChoice() && s.isJunction() {
+		return fmt.Errorf("%w: %s can not be both a choice and a junction", ErrInvalidChoiceStateNode, s.id)
+	}
+
+	hasOtherFields := len(s.States) > 0 || len(s.OnEntry) > 0 || len(s.OnExit) > 0 ||
+		len(s.Invoke) > 0 || len(s.On) > 0 || s.Final
+	if hasOtherFields {
+		return fmt.Errorf("%w: %s must only have choice transitions", ErrInvalidChoiceStateNode, s.id)
+	}
+
+	transitions := s.pseudoStateTransitions()
+	if transitions[len(transitions)-1].guard() != nil {
+		return fmt.Errorf("%w: %s", ErrChoiceWithoutDefaultTransition, s.id)
+	}
+
+	for _, transition := range transitions {
+		if transition.Cond != nil && transition.Guard != nil {
+			return ErrTransitionWithCondAndGuard
+		}
+
+		if transition.Guard != nil {
+			if err := validateGuard(transition.Guard); err != nil {
+				return fmt.Errorf("%w, in a choice transition of %s", err, s.id)
+			}
+		}
+
+		if transition.isTargetBlank() {
+			return fmt.Errorf("%w: the transitions of %s must have a target", ErrInvalidChoiceStateNode, s.id)
+		}
+
+		if _, err := resolveTransitionTarget(s, transition); err != nil {
+			return &ErrInvalidTransitionNotImplementedWithDetails{
+				From:   s,
+				Target: transition.Target,
+				Err:    err,
+			}
+		}
+	}
+
+	return nil
+}
+
+// resolveJunctions follows the junction state nodes starting from targetedStateNode until a state node
+// that is not a junction is reached.
+// Each junction state node picks the first of its transitions whose guard passes, evaluated against
+// the context and the active state nodes from before the microstep.
+// The picked transitions are added to the microstep, and the state node reached is returned.
+// It can be a choice state node, whose transition is picked by a microstep of its own; see planChoiceMicrostep.
+func (step *microstep) resolveJunctions(targetedStateNode *StateNode, current *StateNode, c Context, event Event) (*StateNode, error) {
+	encounteredJunctions := make(map[*StateNode]bool)
+
+	for targetedStateNode.isJunction() {
+		if encounteredJunctions[targetedStateNode] {
+			return nil, fmt.Errorf("%w: %s has already been encountered", ErrChoiceLoop, targetedStateNode.id)
+		}
+		encounteredJunctions[targetedStateNode] = true
+
+		transition, index, guardResults, err := selectTransition(current, c, targetedStateNode.Junction, event)
+		step.recordSelection(targetedStateNode, choiceEventType, index, guardResults)
+		if err != nil {
+			return nil, err
+		}
+
+		step.choiceTransitions = append(step.choiceTransitions, transition)
+
+		targetedStateNode, err = resolveTransitionTarget(targetedStateNode, transition)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return targetedStateNode, nil
+}
+
+// planChoiceMicrostep selects the transition of the choice state node the previous microstep lead to,
+// against the context updated by the actions of the previous microstep, and resolves the state nodes
+// it exits and enters.
+// encounteredChoices holds the choice state nodes already encountered since the event was handled.
+func planChoiceMicrostep(choice *StateNode, c Context, event Event, encounteredChoices map[*StateNode]bool) (microstep, error) {
+	step := microstep{
+		target:  choice,
+		exited:  []*StateNode{},
+		entered: []*StateNode{},
+	}
+
+	if encounteredChoices[choice] {
+		return step, fmt.Errorf("%w: %s has already been encountered", ErrChoiceLoop, choice.id)
+	}
+	encounteredChoices[choice] = true
+
+	transition, index, guardResults, err := selectTransition(choice, c, choice.Choice, event)
+	step.recordSelection(choice, choiceEventType, index, guardResults)
+	if err != nil {
+		return step, err
+	}
+
+	targetedStateNode, err := resolveTransitionTarget(choice, transition)
+	if err != nil {
+		return step, err
+	}
+
+	step.transition = transition
+
+	targetedStateNode, err = step.resolveJunctions(targetedStateNode, choice, c, event)
+	if err != nil {
+		return step, err
+	}
+
+	stateNodeToEnter := targetedStateNode.resolveMostNestedInitialStateNode()
+	domain := transitionDomain(choice, targetedStateNode, transition.External)
+
+	step.target = stateNodeToEnter
+	step.exited = withoutPseudoStates(statesToExit(choice, domain))
+	step.entered = withoutPseudoStates(statesToEnter(stateNodeToEnter, domain))
+
+	return step, nil
+}
+
+// withoutPseudoStates removes choice state nodes from the state nodes exited or entered by a microstep,
+// as they are never active.
+func withoutPseudoStates(stateNodes []*StateNode) []*StateNode {
+	activeStateNodes := make([]*StateNode, 0, len(stateNodes))
+
+	for _, stateNode := range stateNodes {
+		if !stateNode.isPseudoState() {
+			activeStateNodes = append(activeStateNodes, stateNode)
+		}
+	}
+
+	return activeStateNodes
+}
@@ -0,0 +1,515 @@
+package brainy_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Devessier/brainy"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	IdleState     brainy.StateType = "idle"
+	RouteState    brainy.StateType = "route"
+	ReviewState   brainy.StateType = "review"
+	RejectedState brainy.StateType = "rejected"
+	ApprovedState brainy.StateType = "approved"
+
+	SubmitEvent brainy.EventType = "SUBMIT"
+)
+
+type SubmitAmountEvent struct {
+	brainy.EventWithType
+	Amount int
+}
+
+func TestChoiceStateNodesPickATransition(t *testing.T) {
+	amountIsAbove := func(threshold int) brainy.Cond {
+		return func(c brainy.Context, e brainy.Event) bool {
+			return e.(SubmitAmountEvent).Amount > threshold
+		}
+	}
+
+	testCases := []struct {
+		Amount                  int
+		ExpectedState           brainy.StateType
+		ExpectedApprovalActions int
+	}{
+		{
+			Amount:                  1000,
+			ExpectedState:           ReviewState,
+			ExpectedApprovalActions: 0,
+		},
+		{
+			Amount:                  50,
+			ExpectedState:           ApprovedState,
+			ExpectedApprovalActions: 1,
+		},
+		{
+			Amount:                  0,
+			ExpectedState:           RejectedState,
+			ExpectedApprovalActions: 0,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(string(testCase.ExpectedState), func(t *testing.T) {
+			assert := assert.New(t)
+
+			approvalActions := 0
+
+			stateMachine, err := brainy.NewMachine(brainy.StateNode{
+				Initial: IdleState,
+
+				States: brainy.StateNodes{
+					IdleState: &brainy.StateNode{
+						On: brainy.Events{
+							SubmitEvent: RouteState,
+						},
+					},
+
+					RouteState: &brainy.StateNode{
+						Choice: brainy.Transitions{
+							{
+								Cond:   amountIsAbove(100),
+								Target: ReviewState,
+							},
+							{
+								Guard:  brainy.Not(brainy.NamedGuard{Name: "isPositive", Cond: amountIsAbove(0)}),
+								Target: RejectedState,
+							},
+							{
+								Target: ApprovedState,
+								Actions: brainy.Actions{
+									brainy.ActionFn(func(c brainy.Context, e brainy.Event) error {
+										approvalActions++
+										return nil
+									}),
+								},
+							},
+						},
+					},
+
+					ReviewState:   &brainy.StateNode{},
+					RejectedState: &brainy.StateNode{},
+					ApprovedState: &brainy.StateNode{},
+				},
+			})
+			assert.NoError(err)
+
+			nextState, err := stateMachine.Send(SubmitAmountEvent{
+				EventWithType: brainy.EventWithType{
+					Event: SubmitEvent,
+				},
+				Amount: testCase.Amount,
+			})
+			assert.NoError(err)
+			assert.True(nextState.Matches(testCase.ExpectedState))
+			assert.Equal(stateMachine.UnsafeCurrent(), nextState.StateNode)
+			for _, enteredStateNode := range nextState.Entered {
+				assert.False(enteredStateNode.Matches(RouteState))
+			}
+			assert.Equal(testCase.ExpectedApprovalActions, approvalActions)
+		})
+	}
+}
+
+func TestChoiceStateNodesValidation(t *testing.T) {
+	newConfig := func(route *brainy.StateNode, initial brainy.StateType) brainy.StateNode {
+		return brainy.StateNode{
+			Initial: initial,
+
+			States: brainy.StateNodes{
+				IdleState: &brainy.StateNode{
+					On: brainy.Events{
+						SubmitEvent: RouteState,
+					},
+				},
+
+				RouteState: route,
+
+				ApprovedState: &brainy.StateNode{},
+			},
+		}
+	}
+
+	alwaysTrue := func(c brainy.Context, e brainy.Event) bool {
+		return true
+	}
+
+	testCases := []struct {
+		Name          string
+		Config        brainy.StateNode
+		ExpectedError error
+	}{
+		{
+			Name: "without default transition",
+			Config: newConfig(&brainy.StateNode{
+				Choice: brainy.Transitions{
+					{
+						Cond:   alwaysTrue,
+						Target: ApprovedState,
+					},
+				},
+			}, IdleState),
+			ExpectedError: brainy.ErrChoiceWithoutDefaultTransition,
+		},
+		{
+			Name: "with event handlers",
+			Config: newConfig(&brainy.StateNode{
+				Choice: brainy.Transitions{
+					{
+						Target: ApprovedState,
+					},
+				},
+				On: brainy.Events{
+					SubmitEvent: IdleState,
+				},
+			}, IdleState),
+			ExpectedError: brainy.ErrInvalidChoiceStateNode,
+		},
+		{
+			Name: "without target",
+			Config: newConfig(&brainy.StateNode{
+				Choice: brainy.Transitions{
+					{},
+				},
+			}, IdleState),
+			ExpectedError: brainy.ErrInvalidChoiceStateNode,
+		},
+		{
+			Name: "as initial state",
+			Config: newConfig(&brainy.StateNode{
+				Choice: brainy.Transitions{
+					{
+						Target: ApprovedState,
+					},
+				},
+			}, RouteState),
+			ExpectedError: brainy.ErrInvalidChoiceStateNode,
+		},
+		{
+			Name: "with unknown target",
+			Config: newConfig(&brainy.StateNode{
+				Choice: brainy.Transitions{
+					{
+						Target: brainy.StateType("unknown"),
+					},
+				},
+			}, IdleState),
+			ExpectedError: brainy.ErrInvalidTransitionNotImplemented,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			stateMachine, err := brainy.NewMachine(testCase.Config)
+			assert.Nil(stateMachine)
+			assert.ErrorIs(err, testCase.ExpectedError)
+		})
+	}
+}
+
+func TestJunctionStateNodesLoop(t *testing.T) {
+	assert := assert.New(t)
+
+	stateMachine, err := brainy.NewMachine(brainy.StateNode{
+		Initial: IdleState,
+
+		States: brainy.StateNodes{
+			IdleState: &brainy.StateNode{
+				On: brainy.Events{
+					SubmitEvent: RouteState,
+				},
+			},
+
+			RouteState: &brainy.StateNode{
+				Junction: brainy.Transitions{
+					{
+						Target: ReviewState,
+					},
+				},
+			},
+
+			ReviewState: &brainy.StateNode{
+				Junction: brainy.Transitions{
+					{
+						Target: RouteState,
+					},
+				},
+			},
+		},
+	})
+	assert.NoError(err)
+
+	_, err = stateMachine.Send(SubmitEvent)
+	assert.ErrorIs(err, brainy.ErrChoiceLoop)
+	assert.True(stateMachine.Current().Matches(IdleState))
+}
+
+func TestChoiceAndJunctionGuardsContext(t *testing.T) {
+	type ApprovalContext struct {
+		Approvals int
+	}
+
+	isApproved := func(c brainy.Context, e brainy.Event) bool {
+		return c.(ApprovalContext).Approvals > 0
+	}
+
+	newConfig := func(route *brainy.StateNode) brainy.StateNode {
+		return brainy.StateNode{
+			Initial: IdleState,
+			Context: ApprovalContext{},
+
+			States: brainy.StateNodes{
+				IdleState: &brainy.StateNode{
+					On: brainy.Events{
+						SubmitEvent: brainy.Transition{
+							Target: RouteState,
+							Actions: brainy.Actions{
+								brainy.Assign(func(c brainy.Context, e brainy.Event) brainy.Context {
+									return ApprovalContext{Approvals: c.(ApprovalContext).Approvals + 1}
+								}),
+							},
+						},
+					},
+				},
+
+				RouteState: route,
+
+				ApprovedState: &brainy.StateNode{},
+				RejectedState: &brainy.StateNode{},
+			},
+		}
+	}
+
+	transitions := brainy.Transitions{
+		{
+			Cond:   isApproved,
+			Target: ApprovedState,
+		},
+		{
+			Target: RejectedState,
+		},
+	}
+
+	testCases := []struct {
+		Name          string
+		Route         *brainy.StateNode
+		ExpectedState brainy.StateType
+	}{
+		{
+			Name:          "choice sees the context updated by the transition",
+			Route:         &brainy.StateNode{Choice: transitions},
+			ExpectedState: ApprovedState,
+		},
+		{
+			Name:          "junction sees the context from before the transition",
+			Route:         &brainy.StateNode{Junction: transitions},
+			ExpectedState: RejectedState,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			definition, err := brainy.NewDefinition(newConfig(testCase.Route))
+			assert.NoError(err)
+
+			stateMachine, err := brainy.NewMachineFromDefinition(definition)
+			assert.NoError(err)
+
+			initialState, err := definition.InitialState()
+			assert.NoError(err)
+
+			pureState, err := definition.Transition(initialState, SubmitEvent)
+			assert.NoError(err)
+			assert.True(pureState.Matches(testCase.ExpectedState))
+
+			nextState, err := stateMachine.Send(SubmitEvent)
+			assert.NoError(err)
+			assert.True(nextState.Matches(testCase.ExpectedState))
+			assert.Equal(ApprovalContext{Approvals: 1}, nextState.Context)
+			assert.Len(nextState.Entered, 1)
+			assert.Len(nextState.Exited, 1)
+			assert.True(nextState.Exited[0].Matches(IdleState))
+			assert.True(nextState.Entered[0].Matches(testCase.ExpectedState))
+		})
+	}
+}
+
+func TestChoiceStateNodesCanNotBeJunctions(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := brainy.NewDefinition(brainy.StateNode{
+		Initial: IdleState,
+
+		States: brainy.StateNodes{
+			IdleState: &brainy.StateNode{
+				On: brainy.Events{
+					SubmitEvent: RouteState,
+				},
+			},
+
+			RouteState: &brainy.StateNode{
+				Choice:   brainy.Transitions{{Target: IdleState}},
+				Junction: brainy.Transitions{{Target: IdleState}},
+			},
+		},
+	})
+	assert.ErrorIs(err, brainy.ErrInvalidChoiceStateNode)
+}
+
+func TestChoiceStateNodesLoop(t *testing.T) {
+	assert := assert.New(t)
+
+	stateMachine, err := brainy.NewMachine(brainy.StateNode{
+		Initial: IdleState,
+
+		States: brainy.StateNodes{
+			IdleState: &brainy.StateNode{
+				On: brainy.Events{
+					SubmitEvent: RouteState,
+				},
+			},
+
+			RouteState: &brainy.StateNode{
+				Choice: brainy.Transitions{
+					{
+						Target: ReviewState,
+					},
+				},
+			},
+
+			ReviewState: &brainy.StateNode{
+				Choice: brainy.Transitions{
+					{
+						Target: RouteState,
+					},
+				},
+			},
+		},
+	})
+	assert.NoError(err)
+
+	_, err = stateMachine.Send(SubmitEvent)
+	assert.ErrorIs(err, brainy.ErrChoiceLoop)
+	assert.True(stateMachine.Current().Matches(IdleState))
+}
+
+func TestChoiceStateNodesActionErrors(t *testing.T) {
+	errApproval := errors.New("approval failed")
+
+	testCases := []struct {
+		Name  string
+		Route *brainy.StateNode
+	}{
+		{
+			Name: "in a choice transition action",
+			Route: &brainy.StateNode{
+				Choice: brainy.Transitions{
+					{
+						Target: ApprovedState,
+						Actions: brainy.Actions{
+							brainy.ActionFn(func(c brainy.Context, e brainy.Event) error {
+								return errApproval
+							}),
+						},
+					},
+				},
+			},
+		},
+		{
+			Name: "in an entry action of the choice target",
+			Route: &brainy.StateNode{
+				Choice: brainy.Transitions{
+					{
+						Target: RejectedState,
+					},
+				},
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			stateMachine, err := brainy.NewMachine(brainy.StateNode{
+				Initial: IdleState,
+
+				States: brainy.StateNodes{
+					IdleState: &brainy.StateNode{
+						On: brainy.Events{
+							SubmitEvent: RouteState,
+						},
+					},
+
+					RouteState: testCase.Route,
+
+					ApprovedState: &brainy.StateNode{},
+					RejectedState: &brainy.StateNode{
+						OnEntry: brainy.Actions{
+							brainy.ActionFn(func(c brainy.Context, e brainy.Event) error {
+								return errApproval
+							}),
+						},
+					},
+				},
+			})
+			assert.NoError(err)
+
+			// The state machine is not left in the choice state node, and can handle the event again.
+			for attempt := 0; attempt < 2; attempt++ {
+				state, err := stateMachine.Send(SubmitEvent)
+				assert.ErrorIs(err, errApproval)
+				assert.True(state.Matches(IdleState))
+				assert.True(stateMachine.Current().Matches(IdleState))
+				assert.Equal([]brainy.EventType{SubmitEvent}, stateMachine.State().NextEvents())
+			}
+		})
+	}
+}
+
+func TestDefinitionsLeaveChoiceStateNodesOnErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	definition, err := brainy.NewDefinition(brainy.StateNode{
+		Initial: IdleState,
+
+		States: brainy.StateNodes{
+			IdleState: &brainy.StateNode{
+				On: brainy.Events{
+					SubmitEvent: RouteState,
+				},
+			},
+
+			RouteState: &brainy.StateNode{
+				Choice: brainy.Transitions{
+					{
+						Target: ReviewState,
+					},
+				},
+			},
+
+			ReviewState: &brainy.StateNode{
+				Choice: brainy.Transitions{
+					{
+						Target: RouteState,
+					},
+				},
+			},
+		},
+	})
+	assert.NoError(err)
+
+	initialState, err := definition.InitialState()
+	assert.NoError(err)
+
+	state, err := definition.Transition(initialState, SubmitEvent)
+	assert.ErrorIs(err, brainy.ErrChoiceLoop)
+	assert.True(state.Matches(IdleState))
+}
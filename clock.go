@@ -0,0 +1,94 @@
+package brainy
+
+import "time"
+
+// A Clock tells the time and schedules functions. State machines use it to send delayed events.
+// The default clock relies on the time package; tests can provide a clock whose time they control.
+type Clock interface {
+	Now() time.Time
+	// AfterFunc calls f in its own goroutine once d has elapsed, unless the returned timer is stopped before.
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// A Timer is a function scheduled by a Clock.
+type Timer interface {
+	// Stop prevents the function from being called. It returns false if the function has already been called
+	// or if the timer has already been stopped.
+	Stop() bool
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// WithClock sets the clock used by the state machine, and by the children it spawns.
+func WithClock(clock Clock) MachineOption {
+	return func(machine *Machine) {
+		machine.clock = clock
+	}
+}
+
+// delayedEvent is an event scheduled by a SendAfter action.
+type delayedEvent struct {
+	timer Timer
+}
+
+// scheduleEvent schedules the event of the action. Events scheduled by an entry action belong to the state node
+// that is entered, and are cancelled when it is exited; other events belong to the state machine itself.
+func (machine *Machine) scheduleEvent(action sendAfterAction, owner *StateNode) {
+	scheduledEvent := &delayedEvent{}
+	machine.delayedEvents[owner] = append(machine.delayedEvents[owner], scheduledEvent)
+
+	scheduledEvent.timer = machine.clock.AfterFunc(action.Delay, func() {
+		deliverMessages(machine.lockAndSendDelayedEvent(scheduledEvent, action.SourceEvent))
+	})
+}
+
+// cancelDelayedEvents cancels the events scheduled by the entry actions of the state node.
+func (machine *Machine) cancelDelayedEvents(owner *StateNode) {
+	for _, scheduledEvent := range machine.delayedEvents[owner] {
+		scheduledEvent.timer.Stop()
+	}
+
+	delete(machine.delayedEvents, owner)
+}
+
+// lockAndSendDelayedEvent sends the event if it has not been cancelled in the meantime.
+// Errors occuring while the event is processed are not reported, as there is no caller to report them to.
+func (machine *Machine) lockAndSendDelayedEvent(scheduledEvent *delayedEvent, event Event) []message {
+	if !machine.disableLocking {
+		machine.lock.Lock()
+		defer machine.lock.Unlock()
+	}
+
+	if machine.stopped || !machine.removeDelayedEvent(scheduledEvent) {
+		return nil
+	}
+
+	machine.send(event)
+
+	return machine.takeOutbox()
+}
+
+// removeDelayedEvent forgets the scheduled event, and returns whether it was still scheduled.
+func (machine *Machine) removeDelayedEvent(scheduledEvent *delayedEvent) bool {
+	for owner, scheduledEvents := range machine.delayedEvents {
+		for index, scheduledEventToCompare := range scheduledEvents {
+			if scheduledEventToCompare != scheduledEvent {
+				continue
+			}
+
+			machine.delayedEvents[owner] = append(scheduledEvents[:index:index], scheduledEvents[index+1:]...)
+
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,79 @@
+// Command brainy-lint analyzes the graph of a brainy definition and reports unreachable states,
+// dead-end states, shadowed handlers and dead transitions.
+//
+// The graph is read as JSON, from the file given as argument or from the standard input.
+// It can be produced with:
+//
+//  graph, err := json.Marshal(definition.Graph())
+//
+// Usage:
+//
+//  brainy-lint [-json] [graph.json]
+//
+// The command exits with status 1 when issues are found, and 2 when the graph can not be read.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Devessier/brainy"
+)
+
+func main() {
+	outputJSON := flag.Bool("json", false, "print issues as JSON")
+	flag.Parse()
+
+	graph, err := readGraph(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "brainy-lint:", err)
+		os.Exit(2)
+	}
+
+	issues := brainy.Lint(graph)
+
+	if *outputJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		if err := encoder.Encode(issues); err != nil {
+			fmt.Fprintln(os.Stderr, "brainy-lint:", err)
+			os.Exit(2)
+		}
+	} else {
+		for _, issue := range issues {
+			fmt.Println(issue)
+		}
+	}
+
+	if len(issues) > 0 {
+		os.Exit(1)
+	}
+}
+
+// readGraph decodes the graph from the file at path, or from the standard input if path is empty.
+func readGraph(path string) (brainy.Graph, error) {
+	var (
+		reader io.Reader = os.Stdin
+		graph  brainy.Graph
+	)
+
+	if path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			return graph, err
+		}
+		defer file.Close()
+
+		reader = file
+	}
+
+	if err := json.NewDecoder(reader).Decode(&graph); err != nil {
+		return graph, fmt.Errorf("invalid graph: %w", err)
+	}
+
+	return graph, nil
+}
@@ -0,0 +1,15 @@
+// Command brainy-vet reports common misuses of brainy. It is meant to be run by go vet:
+//
+//  go vet -vettool=$(which brainy-vet) ./...
+//
+// See the brainyvet package for the reported issues.
+package main
+
+import (
+	"github.com/Devessier/brainy/brainyvet"
+	"golang.org/x/tools/go/analysis/unitchecker"
+)
+
+func main() {
+	unitchecker.Main(brainyvet.Analyzer)
+}
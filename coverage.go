@@ -0,0 +1,363 @@
+package brainy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// choiceEventType is the event type under which the transitions of choice and junction state nodes are referenced,
+// as they do not handle any event.
+const choiceEventType EventType = ""
+
+// transitionReference identifies the transition of index among the transitions handling eventType in the
+// state node whose id is source.
+// State nodes are referenced by id, so that state machines built from the same configuration
+// share their references.
+type transitionReference struct {
+	source    string
+	eventType EventType
+	index     int
+}
+
+// evaluatedGuard is the result of the guard of a transition.
+type evaluatedGuard struct {
+	transition transitionReference
+	passed     bool
+}
+
+// recordSelection records the guards evaluated to select a transition of the handler of source,
+// and the selected transition if index is not -1.
+func (step *microstep) recordSelection(source *StateNode, eventType EventType, index int, guardResults []guardResult) {
+	for _, result := range guardResults {
+		step.evaluatedGuards = append(step.evaluatedGuards, evaluatedGuard{
+			transition: transitionReference{
+				source:    source.id,
+				eventType: eventType,
+				index:     result.index,
+			},
+			passed: result.passed,
+		})
+	}
+
+	if index == -1 {
+		return
+	}
+
+	step.takenTransitions = append(step.takenTransitions, transitionReference{
+		source:    source.id,
+		eventType: eventType,
+		index:     index,
+	})
+}
+
+// Coverage records which state nodes of a definition are entered, which transitions are taken
+// and which results the guards return, across all the state machines it is given to with WithCoverage.
+// State machines must be built from the definition of the coverage, or from the same configuration.
+//
+// A Coverage is safe for concurrent use.
+type Coverage struct {
+	definition *Definition
+
+	lock             sync.Mutex
+	enteredStates    map[string]int
+	takenTransitions map[transitionReference]int
+	guardResults     map[evaluatedGuard]int
+}
+
+// NewCoverage returns an empty coverage of the definition.
+func NewCoverage(definition *Definition) *Coverage {
+	return &Coverage{
+		definition:       definition,
+		enteredStates:    make(map[string]int),
+		takenTransitions: make(map[transitionReference]int),
+		guardResults:     make(map[evaluatedGuard]int),
+	}
+}
+
+// WithCoverage records the coverage of the state machine in coverage.
+func WithCoverage(coverage *Coverage) MachineOption {
+	return func(machine *Machine) {
+		machine.coverage = coverage
+	}
+}
+
+// recordMicrostep records the state nodes entered, the transitions taken and the guards evaluated by the microstep.
+// It can be called on a nil coverage.
+func (coverage *Coverage) recordMicrostep(step microstep) {
+	if coverage == nil {
+		return
+	}
+
+	coverage.lock.Lock()
+	defer coverage.lock.Unlock()
+
+	for _, stateNode := range step.entered {
+		coverage.enteredStates[stateNode.id]++
+	}
+
+	for _, transition := range step.takenTransitions {
+		coverage.takenTransitions[transition]++
+
+		// Choice and junction state nodes are never entered: they are covered when one of their transitions is taken.
+		if transition.eventType == choiceEventType {
+			coverage.enteredStates[transition.source]++
+		}
+	}
+
+	for _, guard := range step.evaluatedGuards {
+		coverage.guardResults[guard]++
+	}
+}
+
+// StateCoverage reports how many times a state node has been entered.
+// A choice or junction state node is reported as entered each time one of its transitions is taken.
+type StateCoverage struct {
+	ID      string `json:"id"`
+	Entered int    `json:"entered"`
+}
+
+// TransitionCoverage reports how many times a transition has been taken.
+// Index is the position of the transition among the transitions of Event.
+// The transitions of choice state nodes do not have an Event.
+type TransitionCoverage struct {
+	Source string `json:"source"`
+	Event  string `json:"event,omitempty"`
+	Index  int    `json:"index"`
+	Target string `json:"target,omitempty"`
+	Taken  int    `json:"taken"`
+}
+
+// GuardCoverage reports how many times the guard of a transition returned true and false.
+type GuardCoverage struct {
+	Source string `json:"source"`
+	Event  string `json:"event,omitempty"`
+	Index  int    `json:"index"`
+	Guard  string `json:"guard"`
+	True   int    `json:"true"`
+	False  int    `json:"false"`
+}
+
+// A CoverageReport lists the state nodes, transitions and guards of a definition with their coverage,
+// in document order. It can be serialized to JSON.
+//
+// The ratios are between 0 and 1. A guard is covered when it returned both true and false: GuardsRatio is
+// the ratio of guard results that were observed. The ratio of a definition without any item is 1.
+type CoverageReport struct {
+	States      []StateCoverage      `json:"states"`
+	Transitions []TransitionCoverage `json:"transitions"`
+	Guards      []GuardCoverage      `json:"guards"`
+
+	StatesRatio      float64 `json:"statesRatio"`
+	TransitionsRatio float64 `json:"transitionsRatio"`
+	GuardsRatio      float64 `json:"guardsRatio"`
+}
+
+// Report returns the coverage recorded so far. The root state node is not reported as it is always entered.
+func (coverage *Coverage) Report() CoverageReport {
+	coverage.lock.Lock()
+	defer coverage.lock.Unlock()
+
+	report := CoverageReport{
+		States:      make([]StateCoverage, 0),
+		Transitions: make([]TransitionCoverage, 0),
+		Guards:      make([]GuardCoverage, 0),
+	}
+
+	var reportStateNode func(stateNode *StateNode)
+	reportStateNode = func(stateNode *StateNode) {
+		if stateNode != coverage.definition.root {
+			report.States = append(report.States, StateCoverage{
+				ID:      stateNode.id,
+				Entered: coverage.enteredStates[stateNode.id],
+			})
+		}
+
+		for _, eventType := range stateNode.On.sortedEventTypes() {
+			coverage.reportTransitions(&report, stateNode, eventType, stateNode.On[eventType].transitions())
+		}
+		coverage.reportTransitions(&report, stateNode, choiceEventType, stateNode.pseudoStateTransitions())
+
+		for _, childStateNode := range stateNode.childrenInDocumentOrder {
+			reportStateNode(childStateNode)
+		}
+	}
+	reportStateNode(coverage.definition.root)
+
+	report.computeRatios()
+
+	return report
+}
+
+func (coverage *Coverage) reportTransitions(report *CoverageReport, source *StateNode, eventType EventType, transitions []Transition) {
+	for index, transition := range transitions {
+		reference := transitionReference{
+			source:    source.id,
+			eventType: eventType,
+			index:     index,
+		}
+		graphTransition := source.graphTransition(transition)
+
+		report.Transitions = append(report.Transitions, TransitionCoverage{
+			Source: source.id,
+			Event:  string(eventType),
+			Index:  index,
+			Target: graphTransition.Target,
+			Taken:  coverage.takenTransitions[reference],
+		})
+
+		if graphTransition.Guard == "" {
+			continue
+		}
+
+		report.Guards = append(report.Guards, GuardCoverage{
+			Source: source.id,
+			Event:  string(eventType),
+			Index:  index,
+			Guard:  graphTransition.Guard,
+			True:   coverage.guardResults[evaluatedGuard{transition: reference, passed: true}],
+			False:  coverage.guardResults[evaluatedGuard{transition: reference, passed: false}],
+		})
+	}
+}
+
+func (report *CoverageReport) computeRatios() {
+	coveredStates := 0
+	for _, state := range report.States {
+		if state.Entered > 0 {
+			coveredStates++
+		}
+	}
+
+	takenTransitions := 0
+	for _, transition := range report.Transitions {
+		if transition.Taken > 0 {
+			takenTransitions++
+		}
+	}
+
+	observedGuardResults := 0
+	for _, guard := range report.Guards {
+		if guard.True > 0 {
+			observedGuardResults++
+		}
+		if guard.False > 0 {
+			observedGuardResults++
+		}
+	}
+
+	report.StatesRatio = ratio(coveredStates, len(report.States))
+	report.TransitionsRatio = ratio(takenTransitions, len(report.Transitions))
+	report.GuardsRatio = ratio(observedGuardResults, 2*len(report.Guards))
+}
+
+func ratio(covered int, total int) float64 {
+	if total == 0 {
+		return 1
+	}
+
+	return float64(covered) / float64(total)
+}
+
+func formatPercentage(value float64) string {
+	return strconv.FormatFloat(value*100, 'f', 1, 64) + "%"
+}
+
+// describeTransition describes a transition as `source EVENT[index]`; choice transitions are described as
+// `source choice[index]`.
+func describeTransition(source string, event string, index int) string {
+	if event == "" {
+		event = "choice"
+	}
+
+	return source + " " + event + "[" + strconv.Itoa(index) + "]"
+}
+
+// Text returns a human readable report, that lists the uncovered state nodes, transitions and guard results.
+func (report CoverageReport) Text() string {
+	var builder strings.Builder
+
+	fmt.Fprintf(&builder, "states: %s, transitions: %s, guards: %s\n",
+		formatPercentage(report.StatesRatio),
+		formatPercentage(report.TransitionsRatio),
+		formatPercentage(report.GuardsRatio),
+	)
+
+	for _, state := range report.States {
+		if state.Entered == 0 {
+			fmt.Fprintf(&builder, "state not entered: %s\n", state.ID)
+		}
+	}
+
+	for _, transition := range report.Transitions {
+		if transition.Taken == 0 {
+			fmt.Fprintf(&builder, "transition not taken: %s\n", describeTransition(transition.Source, transition.Event, transition.Index))
+		}
+	}
+
+	for _, guard := range report.Guards {
+		missingResults := make([]string, 0, 2)
+		if guard.True == 0 {
+			missingResults = append(missingResults, "true")
+		}
+		if guard.False == 0 {
+			missingResults = append(missingResults, "false")
+		}
+
+		if len(missingResults) > 0 {
+			fmt.Fprintf(&builder, "guard %s of %s never returned %s\n", guard.Guard, describeTransition(guard.Source, guard.Event, guard.Index), strings.Join(missingResults, " nor "))
+		}
+	}
+
+	return builder.String()
+}
+
+// DOT returns a Graphviz diagram of the state nodes and transitions, in which uncovered parts are
+// drawn in red with dashed lines. Targetless transitions are drawn as loops.
+func (report CoverageReport) DOT() string {
+	var builder strings.Builder
+
+	builder.WriteString("digraph coverage {\n")
+
+	for _, state := range report.States {
+		fmt.Fprintf(&builder, "  %q [label=%q%s];\n", state.ID, state.ID, uncoveredAttributes(state.Entered == 0))
+	}
+
+	guardsByTransition := make(map[string]GuardCoverage, len(report.Guards))
+	for _, guard := range report.Guards {
+		guardsByTransition[describeTransition(guard.Source, guard.Event, guard.Index)] = guard
+	}
+
+	for _, transition := range report.Transitions {
+		target := transition.Target
+		if target == "" {
+			target = transition.Source
+		}
+
+		label := transition.Event
+		if label == "" {
+			label = "choice"
+		}
+
+		isUncovered := transition.Taken == 0
+		if guard, ok := guardsByTransition[describeTransition(transition.Source, transition.Event, transition.Index)]; ok {
+			label += " [" + guard.Guard + "]"
+			isUncovered = isUncovered || guard.True == 0 || guard.False == 0
+		}
+
+		fmt.Fprintf(&builder, "  %q -> %q [label=%q%s];\n", transition.Source, target, label, uncoveredAttributes(isUncovered))
+	}
+
+	builder.WriteString("}\n")
+
+	return builder.String()
+}
+
+func uncoveredAttributes(isUncovered bool) string {
+	if !isUncovered {
+		return ""
+	}
+
+	return ", color=red, style=dashed"
+}
@@ -0,0 +1,143 @@
+package brainy_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Devessier/brainy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoverage(t *testing.T) {
+	assert := assert.New(t)
+
+	isUnlocked := false
+
+	definition, err := brainy.NewDefinition(brainy.StateNode{
+		Initial: OffState,
+
+		States: brainy.StateNodes{
+			OffState: &brainy.StateNode{
+				On: brainy.Events{
+					OnEvent: brainy.Transition{
+						Guard: brainy.NamedGuard{
+							Name: "isUnlocked",
+							Cond: func(c brainy.Context, e brainy.Event) bool {
+								return isUnlocked
+							},
+						},
+						Target: OnState,
+					},
+				},
+			},
+
+			OnState: &brainy.StateNode{
+				On: brainy.Events{
+					OffEvent: OffState,
+				},
+			},
+
+			AtomicState: &brainy.StateNode{},
+		},
+
+		Order: []brainy.StateType{OffState, OnState, AtomicState},
+	})
+	assert.NoError(err)
+
+	coverage := brainy.NewCoverage(definition)
+
+	for _, unlocked := range []bool{false, true} {
+		isUnlocked = unlocked
+
+		stateMachine, err := brainy.NewMachineFromDefinition(definition, brainy.WithCoverage(coverage))
+		assert.NoError(err)
+
+		_, err = stateMachine.Send(OnEvent)
+		if !unlocked {
+			assert.ErrorIs(err, brainy.ErrNoTransitionCouldBeRun)
+		}
+	}
+
+	report := coverage.Report()
+	assert.Equal([]brainy.StateCoverage{
+		{ID: "(machine).off", Entered: 2},
+		{ID: "(machine).on", Entered: 1},
+		{ID: "(machine).atomic", Entered: 0},
+	}, report.States)
+	assert.Equal([]brainy.TransitionCoverage{
+		{Source: "(machine).off", Event: string(OnEvent), Index: 0, Target: "(machine).on", Taken: 1},
+		{Source: "(machine).on", Event: string(OffEvent), Index: 0, Target: "(machine).off", Taken: 0},
+	}, report.Transitions)
+	assert.Equal([]brainy.GuardCoverage{
+		{Source: "(machine).off", Event: string(OnEvent), Index: 0, Guard: "isUnlocked", True: 1, False: 1},
+	}, report.Guards)
+	assert.InDelta(2.0/3.0, report.StatesRatio, 0.001)
+	assert.Equal(0.5, report.TransitionsRatio)
+	assert.Equal(1.0, report.GuardsRatio)
+
+	assert.Equal(`states: 66.7%, transitions: 50.0%, guards: 100.0%
+state not entered: (machine).atomic
+transition not taken: (machine).on off[0]
+`, report.Text())
+
+	assert.Equal(`digraph coverage {
+  "(machine).off" [label="(machine).off"];
+  "(machine).on" [label="(machine).on"];
+  "(machine).atomic" [label="(machine).atomic", color=red, style=dashed];
+  "(machine).off" -> "(machine).on" [label="on [isUnlocked]"];
+  "(machine).on" -> "(machine).off" [label="off", color=red, style=dashed];
+}
+`, report.DOT())
+
+	serializedReport, err := json.Marshal(report)
+	assert.NoError(err)
+
+	var deserializedReport brainy.CoverageReport
+	assert.NoError(json.Unmarshal(serializedReport, &deserializedReport))
+	assert.Equal(report, deserializedReport)
+}
+
+func TestCoverageOfChoiceStateNodes(t *testing.T) {
+	assert := assert.New(t)
+
+	definition, err := brainy.NewDefinition(brainy.StateNode{
+		Initial: IdleState,
+
+		States: brainy.StateNodes{
+			IdleState: &brainy.StateNode{
+				On: brainy.Events{
+					SubmitEvent: RouteState,
+				},
+			},
+
+			RouteState: &brainy.StateNode{
+				Choice: brainy.Transitions{
+					{Target: ApprovedState},
+				},
+			},
+
+			ApprovedState: &brainy.StateNode{},
+		},
+
+		Order: []brainy.StateType{IdleState, RouteState, ApprovedState},
+	})
+	assert.NoError(err)
+
+	coverage := brainy.NewCoverage(definition)
+
+	stateMachine, err := brainy.NewMachineFromDefinition(definition, brainy.WithCoverage(coverage))
+	assert.NoError(err)
+
+	_, err = stateMachine.Send(SubmitEvent)
+	assert.NoError(err)
+
+	report := coverage.Report()
+	assert.Equal([]brainy.StateCoverage{
+		{ID: "(machine).idle", Entered: 1},
+		{ID: "(machine).route", Entered: 1},
+		{ID: "(machine).approved", Entered: 1},
+	}, report.States)
+	assert.Equal(1.0, report.StatesRatio)
+	assert.Equal(1.0, report.TransitionsRatio)
+	assert.Equal("states: 100.0%, transitions: 100.0%, guards: 100.0%\n", report.Text())
+}
@@ -0,0 +1,417 @@
+package brainy
+
+import "errors"
+
+// ErrStateNotInDefinition is returned by Definition.Transition when the state node of the given state
+// does not belong to the definition.
+var ErrStateNotInDefinition = errors.New("state node does not belong to the definition")
+
+// rootStateNodeID is the id of the root state node of a definition.
+const rootStateNodeID StateType = "(machine)"
+
+// A Definition is a validated state nodes configuration.
+//
+// A Definition is not bound to a state machine: it is used by Machine to run the state chart, and
+// its Transition method computes what would happen when an event is sent, without any side effect.
+type Definition struct {
+	root           *StateNode
+	stateNodesByID map[string][]*StateNode
+}
+
+// NewDefinition takes a StateNode configuration and returns a Definition if the configuration is valid.
+// If the configuration is invalid, an ErrInvalidDefinition error listing every problem is returned.
+//
+// The state nodes of the configuration are owned by the definition and must not be reused in another configuration.
+func NewDefinition(config StateNode) (*Definition, error) {
+	definition := &Definition{
+		root: &config,
+	}
+
+	definition.setStateNodesIDs()
+
+	if err := definition.root.validate(); err != nil {
+		return nil, err
+	}
+
+	return definition, nil
+}
+
+func (definition *Definition) setStateNodesIDs() {
+	definition.root.id = string(rootStateNodeID)
+	definition.root.machineID = rootStateNodeID
+	definition.root.definition = definition
+
+	definition.root.setChildrenStateNodesIDs(string(rootStateNodeID), rootStateNodeID, definition)
+	definition.indexStateNodesIDs()
+}
+
+// Root returns the root state node of the definition.
+func (definition *Definition) Root() *StateNode {
+	return definition.root
+}
+
+// InitialState returns the state reached by the initial transition, without running any side effect.
+//
+// Only Assign and Send actions are interpreted: the context of the returned state is the result of Assign actions
+// applied to the context of the root state node, and the events sent by Send actions are processed.
+func (definition *Definition) InitialState() (State, error) {
+	step := definition.initialMicrostep()
+
+	return definition.runMacrostep(nil, definition.root.Context, step, InitialTransitionEventType)
+}
+
+// Transition computes the state the state machine would reach if event was sent to it while being in state,
+// without running any side effect and without mutating any Machine.
+//
+// The returned state holds the ordered list of actions that would run. Only Assign and Send actions are
+// interpreted, to compute the new context and to process the events sent to the state machine itself.
+// Guards are evaluated, so they should not have side effects.
+//
+// As with Machine.Send, if an error occurs while an event sent by a Send action is processed,
+// the state reached so far is returned with the error.
+func (definition *Definition) Transition(state State, event Event) (State, error) {
+	if state.StateNode == nil || state.StateNode.definition != definition {
+		return state, ErrStateNotInDefinition
+	}
+
+	step, err := planMicrostep(state.StateNode, state.Context, event)
+	if err != nil {
+		return state, err
+	}
+
+	return definition.runMacrostep(state.StateNode, state.Context, step, event)
+}
+
+// runMacrostep interprets the actions of the microstep, follows the choice state nodes it leads to,
+// and then processes the events sent by Send actions until none remain.
+func (definition *Definition) runMacrostep(previous *StateNode, c Context, step microstep, event Event) (State, error) {
+	record := newMacrostepRecord()
+	internalEvents := newEventsQueue()
+	sourceEvent := event
+	encounteredChoices := make(map[*StateNode]bool)
+	// current is the last state node reached that is not a pseudo-state.
+	current := previous
+
+	for {
+		for _, action := range step.actions() {
+			record.actions = append(record.actions, action.actioner)
+
+			switch actioner := action.actioner.(type) {
+			case assignAction:
+				c = actioner.Fn(c, event)
+			case sendActionEvent:
+				internalEvents.Add(actioner.SourceEvent)
+			}
+		}
+
+		record.exited = append(record.exited, step.exited...)
+		record.entered = append(record.entered, step.entered...)
+
+		if step.target.isChoice() {
+			nextStep, err := planChoiceMicrostep(step.target, c, event, encounteredChoices)
+			if err != nil {
+				return newState(previous, current, c, sourceEvent, record), err
+			}
+
+			step = nextStep
+			continue
+		}
+
+		current = step.target
+		encounteredChoices = make(map[*StateNode]bool)
+
+		internalEvent, ok := internalEvents.Poll()
+		if !ok {
+			return newState(previous, step.target, c, sourceEvent, record), nil
+		}
+
+		nextStep, err := planMicrostep(step.target, c, internalEvent)
+		if err != nil {
+			return newState(previous, step.target, c, sourceEvent, record), err
+		}
+
+		step = nextStep
+		event = internalEvent
+	}
+}
+
+// initialMicrostep returns the microstep of the initial transition.
+//
+// The least common compound ancestor is nil as we want the OnEntry actions of the root state node:
+// during the initial transition, the least common compound ancestor is the parent of the root state,
+// that is, in our implementation, nil, as it does not have any parent.
+func (definition *Definition) initialMicrostep() microstep {
+	target := definition.root.resolveMostNestedInitialStateNode()
+
+	return microstep{
+		target:  target,
+		exited:  []*StateNode{},
+		entered: statesToEnter(target, nil),
+	}
+}
+
+// plannedAction is an action that will be run during a microstep.
+type plannedAction struct {
+	actioner   Actioner
+	actionType actionType
+	// index is the index of the action used in ErrAction errors.
+	index int
+	// owner is the state node that is entered, for entry actions.
+	owner *StateNode
+}
+
+// A microstep describes the state nodes that are exited and entered when a transition is taken.
+type microstep struct {
+	transition Transition
+	// choiceTransitions holds the transitions picked by the junction state nodes the transition went through.
+	choiceTransitions []Transition
+	target            *StateNode
+	// takenTransitions and evaluatedGuards reference the transitions taken, including the ones of choice state nodes,
+	// and the guards evaluated to select them. They are used to measure coverage.
+	takenTransitions []transitionReference
+	evaluatedGuards  []evaluatedGuard
+	// exited holds the state nodes in the order they are exited, that is, from the most nested one.
+	exited []*StateNode
+	// entered holds the state nodes in the order they are entered, that is, from the least nested one.
+	entered []*StateNode
+}
+
+// actions returns all actions of the microstep, in the order they must be run.
+func (step microstep) actions() []plannedAction {
+	actions := make([]plannedAction, 0)
+
+	for _, stateNode := range step.exited {
+		actions = append(actions, stateNode.exitActions()...)
+	}
+
+	actions = append(actions, step.transitionActions()...)
+	actions = append(actions, entryActions(step.entered)...)
+
+	return actions
+}
+
+// transitionActions returns the actions of the transition, followed by the actions of the transitions
+// picked by junction state nodes.
+func (step microstep) transitionActions() []plannedAction {
+	actions := step.transition.plannedActions()
+
+	for _, choiceTransition := range step.choiceTransitions {
+		actions = append(actions, choiceTransition.plannedActions()...)
+	}
+
+	return actions
+}
+
+// planMicrostep selects the transition that handles the event from the current state node,
+// and resolves the state nodes it exits and enters.
+// If no transition could be selected, the returned microstep only holds the guards that were evaluated.
+func planMicrostep(current *StateNode, c Context, event Event) (microstep, error) {
+	stateNodeWithHandler, descriptor, eventHandler := resolveStateNodeWithHandler(current, event.eventType())
+	if stateNodeWithHandler == nil {
+		return microstep{}, &ErrNoHandlerToHandleEvent{
+			Event: event,
+		}
+	}
+
+	step := microstep{
+		target:  current,
+		exited:  []*StateNode{},
+		entered: []*StateNode{},
+	}
+
+	if isForbiddenTransition(eventHandler) {
+		return step, nil
+	}
+
+	transitionToExecute, index, guardResults, err := selectTransition(current, c, eventHandler.transitions(), event)
+	step.recordSelection(stateNodeWithHandler, descriptor, index, guardResults)
+	if err != nil {
+		return step, err
+	}
+
+	step.transition = transitionToExecute
+
+	if transitionToExecute.isTargetBlank() {
+		return step, nil
+	}
+
+	targetedStateNode, err := resolveTransitionTarget(stateNodeWithHandler, transitionToExecute)
+	if err != nil {
+		return microstep{}, err
+	}
+
+	targetedStateNode, err = step.resolveJunctions(targetedStateNode, current, c, event)
+	if err != nil {
+		return step, err
+	}
+
+	stateNodeToEnter := targetedStateNode.resolveMostNestedInitialStateNode()
+	domain := transitionDomain(stateNodeWithHandler, targetedStateNode, transitionToExecute.External)
+
+	// When a choice state node is targeted, it becomes the target of the microstep
+	// until its transition is picked by the next microstep.
+	step.target = stateNodeToEnter
+	step.exited = statesToExit(current, domain)
+	step.entered = withoutPseudoStates(statesToEnter(stateNodeToEnter, domain))
+
+	return step, nil
+}
+
+// transitionDomain returns the state node whose active descendants are exited and entered when a transition
+// from source to target is taken, as defined by the SCXML specification:
+//
+// 1. an internal transition from a compound state node to one of its descendants does not exit the source
+//
+// 2. otherwise, the domain is the least common compound ancestor of the source and the target,
+// so an external transition to a descendant of the source, or any transition to the source itself,
+// exits and reenters the source
+//
+// The root state node is never exited.
+func transitionDomain(source *StateNode, target *StateNode, external bool) *StateNode {
+	if !external && source.isCompound() && target.isDescendantOf(source) {
+		return source
+	}
+
+	if isRootStateNode := source.parentStateNode == nil; isRootStateNode {
+		return source
+	}
+
+	return findLeastCommonCompoundAncestor([]*StateNode{source, target})
+}
+
+func statesToExit(current *StateNode, domain *StateNode) []*StateNode {
+	exited := make([]*StateNode, 0)
+
+	for stateNode := current; stateNode != domain; stateNode = stateNode.parentStateNode {
+		exited = append(exited, stateNode)
+	}
+
+	return exited
+}
+
+func statesToEnter(target *StateNode, domain *StateNode) []*StateNode {
+	entered := statesToExit(target, domain)
+
+	for i, j := 0, len(entered)-1; i < j; i, j = i+1, j-1 {
+		entered[i], entered[j] = entered[j], entered[i]
+	}
+
+	return entered
+}
+
+func (s *StateNode) exitActions() []plannedAction {
+	actions := make([]plannedAction, 0, len(s.OnExit))
+
+	for index, actioner := range s.OnExit {
+		actions = append(actions, plannedAction{
+			actioner:   actioner,
+			actionType: onExitActionType,
+			index:      index,
+		})
+	}
+
+	return actions
+}
+
+func (t Transition) plannedActions() []plannedAction {
+	actions := make([]plannedAction, 0, len(t.Actions))
+
+	for index, actioner := range t.Actions {
+		actions = append(actions, plannedAction{
+			actioner:   actioner,
+			actionType: transitionActionActionType,
+			index:      index,
+		})
+	}
+
+	return actions
+}
+
+// entryActions returns the entry actions of the entered state nodes.
+// Actions are gathered from the most nested state node and then run in reverse order,
+// and their index is their position in the reversed list.
+func entryActions(entered []*StateNode) []plannedAction {
+	actionsToCall := make([]plannedAction, 0)
+
+	for index := len(entered) - 1; index >= 0; index-- {
+		stateNodeToEntry := entered[index]
+
+		for _, actioner := range stateNodeToEntry.OnEntry {
+			actionsToCall = append(actionsToCall, plannedAction{
+				actioner:   actioner,
+				actionType: onEntryActionType,
+				owner:      stateNodeToEntry,
+			})
+		}
+	}
+
+	countOfActions := len(actionsToCall)
+	actionsToCallInReverseOrder := make([]plannedAction, countOfActions)
+
+	for index, action := range actionsToCall {
+		action.index = countOfActions - index - 1
+		actionsToCallInReverseOrder[action.index] = action
+	}
+
+	return actionsToCallInReverseOrder
+}
+
+// resolveStateNodeWithHandler returns the first state node, from current up to the root state node,
+// with a descriptor matching the event type, its most specific descriptor and the handler of this descriptor.
+// A descriptor on a nested state node is preferred over a more specific one on its ancestors.
+// The search stops at a ForbiddenTransition, which is returned as the handler.
+func resolveStateNodeWithHandler(current *StateNode, eventType EventType) (*StateNode, EventType, Transitioner) {
+	stateNode := current
+
+	for stateNode != nil {
+		handlers := stateNode.On
+		if handlers == nil {
+			stateNode = stateNode.parentStateNode
+			continue
+		}
+
+		descriptor, eventHandler := handlers.handlerFor(eventType)
+		if eventHandler == nil {
+			stateNode = stateNode.parentStateNode
+			continue
+		}
+
+		return stateNode, descriptor, eventHandler
+	}
+
+	return nil, "", nil
+}
+
+// guardResult is the result of the guard of the transition of index in the transitions of a handler.
+type guardResult struct {
+	index  int
+	passed bool
+}
+
+// selectTransition returns the first transition whose guard passes, and its index.
+// The results of the guards evaluated to select it are returned, even if no transition could be selected.
+func selectTransition(current *StateNode, c Context, transitions []Transition, event Event) (Transition, int, []guardResult, error) {
+	evaluatedGuards := make([]string, 0, len(transitions))
+	guardResults := make([]guardResult, 0, len(transitions))
+
+	for index, transition := range transitions {
+		shouldCommitTransition := true
+		if guard := transition.guard(); guard != nil {
+			shouldCommitTransition = guard.check(current, c, event)
+			evaluatedGuards = append(evaluatedGuards, guard.String())
+			guardResults = append(guardResults, guardResult{
+				index:  index,
+				passed: shouldCommitTransition,
+			})
+		}
+
+		if shouldCommitTransition {
+			return transition, index, guardResults, nil
+		}
+	}
+
+	return Transition{}, -1, guardResults, &ErrNoTransitionCouldBeRunWithDetails{
+		Event:  event,
+		Guards: evaluatedGuards,
+	}
+}
@@ -0,0 +1,122 @@
+package brainy_test
+
+import (
+	"testing"
+
+	"github.com/Devessier/brainy"
+	"github.com/Devessier/brainy/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+type CounterContext struct {
+	Count int
+}
+
+func newCounterDefinitionConfig(sideEffect *mocks.Action) brainy.StateNode {
+	increment := brainy.Assign(func(c brainy.Context, e brainy.Event) brainy.Context {
+		incrementEvent := e.(IncrementEvent)
+
+		return CounterContext{
+			Count: c.(CounterContext).Count + incrementEvent.IncrementBy,
+		}
+	})
+
+	return brainy.StateNode{
+		Context: CounterContext{},
+
+		Initial: OffState,
+
+		States: brainy.StateNodes{
+			OffState: &brainy.StateNode{
+				OnExit: brainy.Actions{
+					brainy.ActionFn(sideEffect.Execute),
+				},
+
+				On: brainy.Events{
+					IncrementEventType: brainy.Transition{
+						Target: IncrementState,
+						Actions: brainy.Actions{
+							increment,
+							brainy.Send(OnEvent),
+						},
+					},
+				},
+			},
+
+			IncrementState: &brainy.StateNode{
+				On: brainy.Events{
+					OnEvent: OnState,
+				},
+			},
+
+			OnState: &brainy.StateNode{},
+		},
+	}
+}
+
+func TestDefinitionTransitionIsPure(t *testing.T) {
+	assert := assert.New(t)
+
+	sideEffect := new(mocks.Action)
+
+	definition, err := brainy.NewDefinition(newCounterDefinitionConfig(sideEffect))
+	assert.NoError(err)
+
+	initialState, err := definition.InitialState()
+	assert.NoError(err)
+	assert.True(initialState.Matches(OffState))
+	assert.Equal(CounterContext{}, initialState.Context)
+
+	nextState, err := definition.Transition(initialState, NewIncrementEvent(3))
+	assert.NoError(err)
+	assert.True(nextState.Matches(OnState))
+	assert.Equal(CounterContext{Count: 3}, nextState.Context)
+	assert.Len(nextState.Actions, 3)
+
+	// The initial state is left untouched and no side effect has been run.
+	assert.True(initialState.Matches(OffState))
+	assert.Equal(CounterContext{}, initialState.Context)
+	sideEffect.AssertNumberOfCalls(t, "Execute", 0)
+
+	_, err = definition.Transition(nextState, UnknownEventType)
+	assert.ErrorIs(err, &brainy.ErrNoHandlerToHandleEvent{
+		Event: UnknownEventType,
+	})
+}
+
+func TestDefinitionTransitionRejectsForeignStates(t *testing.T) {
+	assert := assert.New(t)
+
+	definition, err := brainy.NewDefinition(newCounterDefinitionConfig(new(mocks.Action)))
+	assert.NoError(err)
+
+	otherDefinition, err := brainy.NewDefinition(newCounterDefinitionConfig(new(mocks.Action)))
+	assert.NoError(err)
+
+	otherInitialState, err := otherDefinition.InitialState()
+	assert.NoError(err)
+
+	_, err = definition.Transition(otherInitialState, NewIncrementEvent(1))
+	assert.ErrorIs(err, brainy.ErrStateNotInDefinition)
+}
+
+func TestAssignReplacesMachineContext(t *testing.T) {
+	assert := assert.New(t)
+
+	sideEffect := new(mocks.Action)
+	sideEffect.On("Execute", CounterContext{}, NewIncrementEvent(2)).Return(nil)
+
+	definition, err := brainy.NewDefinition(newCounterDefinitionConfig(sideEffect))
+	assert.NoError(err)
+
+	stateMachine, err := brainy.NewMachineFromDefinition(definition)
+	assert.NoError(err)
+
+	_, err = stateMachine.Send(NewIncrementEvent(2))
+	assert.NoError(err)
+	assert.True(stateMachine.Current().Matches(OnState))
+	assert.Equal(CounterContext{Count: 2}, stateMachine.Context())
+	assert.Equal(CounterContext{}, definition.Root().Context)
+
+	sideEffect.AssertExpectations(t)
+}
@@ -0,0 +1,65 @@
+package brainy
+
+import "strings"
+
+// WildcardEventType is an event descriptor matching any event.
+const WildcardEventType EventType = "*"
+
+// eventDescriptorWildcardSuffix ends descriptors such as `error.*`, that are equivalent to `error`.
+const eventDescriptorWildcardSuffix = ".*"
+
+// eventDescriptorSpecificity returns how specifically the descriptor matches the event type,
+// or -1 if it does not match it.
+//
+// Event types are made of segments separated by dots. Following SCXML, a descriptor matches
+// an event type if its segments are the first segments of the event type: `done.invoke` matches
+// `done.invoke.fetch` but not `done.invoked`. A descriptor can end with `.*`, which does not change
+// what it matches, and `*` matches any event type.
+//
+// The more segments the descriptor has, the more specific it is. `*` has no specificity.
+func eventDescriptorSpecificity(descriptor EventType, eventType EventType) int {
+	if descriptor == WildcardEventType {
+		return 0
+	}
+
+	prefix := strings.TrimSuffix(string(descriptor), eventDescriptorWildcardSuffix)
+	if string(eventType) != prefix && !strings.HasPrefix(string(eventType), prefix+".") {
+		return -1
+	}
+
+	return strings.Count(prefix, ".") + 1
+}
+
+// isWildcardDescriptor returns whether the descriptor ends with a wildcard, such as `*` or `error.*`,
+// and can therefore not be an event type.
+func isWildcardDescriptor(descriptor EventType) bool {
+	return descriptor == WildcardEventType || strings.HasSuffix(string(descriptor), eventDescriptorWildcardSuffix)
+}
+
+// handlerFor returns the event handler whose descriptor matches the event type the most specifically,
+// and its descriptor.
+// An exact match is always preferred, then the descriptor with the most segments; descriptors
+// ending with `.*` come last among descriptors of the same specificity.
+func (e Events) handlerFor(eventType EventType) (EventType, Transitioner) {
+	if eventHandler, ok := e[eventType]; ok {
+		return eventType, eventHandler
+	}
+
+	var (
+		matchingDescriptor EventType
+		eventHandler       Transitioner
+		bestSpecificity    = -1
+	)
+
+	// Descriptors are sorted, so that `error` is considered before `error.*`.
+	for _, descriptor := range e.sortedEventTypes() {
+		specificity := eventDescriptorSpecificity(descriptor, eventType)
+		if specificity > bestSpecificity {
+			matchingDescriptor = descriptor
+			eventHandler = e[descriptor]
+			bestSpecificity = specificity
+		}
+	}
+
+	return matchingDescriptor, eventHandler
+}
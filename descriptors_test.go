@@ -0,0 +1,171 @@
+package brainy_test
+
+import (
+	"testing"
+
+	"github.com/Devessier/brainy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventDescriptors(t *testing.T) {
+	const (
+		PlatformErrorState brainy.StateType = "platform-error"
+		ErrorState         brainy.StateType = "error"
+		DoneState          brainy.StateType = "done"
+		AnyState           brainy.StateType = "any"
+		ExactState         brainy.StateType = "exact"
+		ParentState        brainy.StateType = "parent"
+	)
+
+	newStateMachine := func(t *testing.T) *brainy.Machine {
+		stateMachine, err := brainy.NewMachine(brainy.StateNode{
+			Initial: CompoundState,
+
+			States: brainy.StateNodes{
+				CompoundState: &brainy.StateNode{
+					Initial: AtomicState,
+
+					States: brainy.StateNodes{
+						AtomicState: &brainy.StateNode{
+							On: brainy.Events{
+								"error.platform":         brainy.AbsoluteTarget(PlatformErrorState),
+								"error.*":                brainy.AbsoluteTarget(ErrorState),
+								"done.invoke":            brainy.AbsoluteTarget(DoneState),
+								"done.invoke.exact":      brainy.AbsoluteTarget(ExactState),
+								brainy.WildcardEventType: brainy.AbsoluteTarget(AnyState),
+							},
+						},
+					},
+
+					On: brainy.Events{
+						"error.platform.fetch": ParentState,
+					},
+				},
+
+				PlatformErrorState: &brainy.StateNode{},
+				ErrorState:         &brainy.StateNode{},
+				DoneState:          &brainy.StateNode{},
+				ExactState:         &brainy.StateNode{},
+				AnyState:           &brainy.StateNode{},
+				ParentState:        &brainy.StateNode{},
+			},
+		})
+		assert.NoError(t, err)
+
+		return stateMachine
+	}
+
+	testCases := []struct {
+		Event         brainy.EventType
+		ExpectedState brainy.StateType
+	}{
+		{
+			Event:         "error.platform.fetch",
+			ExpectedState: PlatformErrorState,
+		},
+		{
+			Event:         "error.platform",
+			ExpectedState: PlatformErrorState,
+		},
+		{
+			Event:         "error.execution",
+			ExpectedState: ErrorState,
+		},
+		{
+			Event:         "error",
+			ExpectedState: ErrorState,
+		},
+		{
+			Event:         brainy.DoneInvokeEventType("fetch"),
+			ExpectedState: DoneState,
+		},
+		{
+			Event:         brainy.DoneInvokeEventType("exact"),
+			ExpectedState: ExactState,
+		},
+		{
+			Event:         "errors",
+			ExpectedState: AnyState,
+		},
+		{
+			Event:         "done.invoked",
+			ExpectedState: AnyState,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(string(testCase.Event), func(t *testing.T) {
+			assert := assert.New(t)
+
+			stateMachine := newStateMachine(t)
+			assert.True(stateMachine.Can(testCase.Event))
+
+			nextState, err := stateMachine.Send(testCase.Event)
+			assert.NoError(err)
+			assert.True(nextState.Matches(testCase.ExpectedState))
+		})
+	}
+}
+
+func TestNextEventsResolvesDescriptors(t *testing.T) {
+	assert := assert.New(t)
+
+	const (
+		ChildState  brainy.StateType = "child"
+		TargetState brainy.StateType = "target"
+
+		HandledEvent brainy.EventType = "X"
+	)
+
+	t.Run("forbidden wildcard", func(t *testing.T) {
+		stateMachine, err := brainy.NewMachine(brainy.StateNode{
+			Initial: CompoundState,
+
+			States: brainy.StateNodes{
+				CompoundState: &brainy.StateNode{
+					Initial: ChildState,
+
+					States: brainy.StateNodes{
+						ChildState: &brainy.StateNode{
+							On: brainy.Events{
+								brainy.WildcardEventType: brainy.ForbiddenTransition{},
+							},
+						},
+					},
+
+					On: brainy.Events{
+						HandledEvent: brainy.AbsoluteTarget(TargetState),
+					},
+				},
+
+				TargetState: &brainy.StateNode{},
+			},
+		})
+		assert.NoError(err)
+
+		assert.False(stateMachine.Can(HandledEvent))
+		assert.Equal([]brainy.EventType{}, stateMachine.NextEvents())
+	})
+
+	t.Run("wildcard descriptors are not listed", func(t *testing.T) {
+		stateMachine, err := brainy.NewMachine(brainy.StateNode{
+			Initial: ChildState,
+
+			States: brainy.StateNodes{
+				ChildState: &brainy.StateNode{
+					On: brainy.Events{
+						brainy.WildcardEventType: TargetState,
+						"error.*":                TargetState,
+						"done.invoke":            TargetState,
+					},
+				},
+
+				TargetState: &brainy.StateNode{},
+			},
+		})
+		assert.NoError(err)
+
+		assert.Equal([]brainy.EventType{"done.invoke"}, stateMachine.NextEvents())
+		assert.Equal(stateMachine.NextEvents(), stateMachine.State().NextEvents())
+	})
+}
@@ -0,0 +1,109 @@
+package brainy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// An EventLogEntry is a line of an event log, describing an event processed by a state machine
+// and the state it lead to.
+type EventLogEntry struct {
+	Time time.Time `json:"time"`
+	// Event is the type of the event.
+	Event EventType `json:"event"`
+	// Payload is the event serialized as JSON. It is omitted when the event is an EventType.
+	Payload json.RawMessage `json:"payload,omitempty"`
+	// State is the state value reached once the event has been processed, as returned by StateValue.String.
+	State string `json:"state"`
+	// Error is the message of the error returned while the event was processed, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// An EventRecorder appends the events processed by a state machine to an event log, in the JSON Lines format.
+// Events sent with Send, delayed events, events sent by invoked services and events received from
+// other state machines are recorded; events raised while an event is processed are not, as replaying
+// the event raises them again. The initial transition is not recorded either.
+//
+// Writing errors do not interrupt the state machine: the first one is kept and returned by Err,
+// and no more events are recorded.
+//
+// An EventRecorder is safe for concurrent use, but should be given to a single state machine
+// so that the log can be replayed. It is not given to spawned children.
+type EventRecorder struct {
+	lock    sync.Mutex
+	encoder *json.Encoder
+	err     error
+}
+
+// NewEventRecorder returns a recorder writing the event log to w.
+func NewEventRecorder(w io.Writer) *EventRecorder {
+	return &EventRecorder{
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// WithEventRecorder records the events processed by the state machine with the recorder.
+func WithEventRecorder(recorder *EventRecorder) MachineOption {
+	return func(machine *Machine) {
+		machine.eventRecorder = recorder
+	}
+}
+
+// Err returns the first error that occured while an event was recorded, or nil.
+func (recorder *EventRecorder) Err() error {
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+
+	return recorder.err
+}
+
+// record appends the event and the state it lead to to the event log.
+// It can be called on a nil recorder.
+func (recorder *EventRecorder) record(now time.Time, event Event, state State, err error) {
+	if recorder == nil {
+		return
+	}
+
+	recorder.lock.Lock()
+	defer recorder.lock.Unlock()
+
+	if recorder.err != nil {
+		return
+	}
+
+	entry := EventLogEntry{
+		Time:  now,
+		Event: event.eventType(),
+		State: state.Value.String(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	payload, err := encodeEventPayload(event)
+	if err != nil {
+		recorder.err = err
+
+		return
+	}
+	entry.Payload = payload
+
+	recorder.err = recorder.encoder.Encode(entry)
+}
+
+// encodeEventPayload serializes the event as JSON. Events that are an EventType have no payload.
+func encodeEventPayload(event Event) (json.RawMessage, error) {
+	if _, isEventType := event.(EventType); isEventType {
+		return nil, nil
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("could not serialize event %s: %w", event.eventType(), err)
+	}
+
+	return payload, nil
+}
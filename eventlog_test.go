@@ -0,0 +1,253 @@
+package brainy_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Devessier/brainy"
+	"github.com/Devessier/brainy/brainytest"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	ReplayIdleState     brainy.StateType = "idle"
+	ReplayPayingState   brainy.StateType = "paying"
+	ReplayPaidState     brainy.StateType = "paid"
+	ReplayTimedOutState brainy.StateType = "timed_out"
+
+	ReplayPayEventType     brainy.EventType = "PAY"
+	ReplayConfirmEventType brainy.EventType = "CONFIRM"
+	ReplayTimeoutEventType brainy.EventType = "TIMEOUT"
+)
+
+type ReplayPayEvent struct {
+	brainy.EventWithType
+
+	Amount int
+}
+
+func replayMachineConfig(maximumAmount int) brainy.StateNode {
+	return brainy.StateNode{
+		Initial: ReplayIdleState,
+
+		States: brainy.StateNodes{
+			ReplayIdleState: &brainy.StateNode{
+				On: brainy.Events{
+					ReplayPayEventType: brainy.Transition{
+						Target: ReplayPayingState,
+						Cond: func(c brainy.Context, e brainy.Event) bool {
+							return e.(ReplayPayEvent).Amount <= maximumAmount
+						},
+					},
+				},
+			},
+
+			ReplayPayingState: &brainy.StateNode{
+				OnEntry: brainy.Actions{
+					brainy.SendAfter(time.Minute, ReplayTimeoutEventType),
+				},
+
+				On: brainy.Events{
+					ReplayConfirmEventType: ReplayPaidState,
+					ReplayTimeoutEventType: ReplayTimedOutState,
+				},
+			},
+
+			ReplayPaidState: &brainy.StateNode{},
+
+			ReplayTimedOutState: &brainy.StateNode{
+				On: brainy.Events{
+					ReplayPayEventType: ReplayPayingState,
+				},
+			},
+		},
+	}
+}
+
+func recordReplayMachineLog(t *testing.T) string {
+	var log bytes.Buffer
+
+	recorder := brainy.NewEventRecorder(&log)
+	machine, clock := brainytest.NewMachine(t, replayMachineConfig(100), brainy.WithEventRecorder(recorder))
+
+	pay := ReplayPayEvent{
+		EventWithType: brainy.EventWithType{Event: ReplayPayEventType},
+		Amount:        50,
+	}
+
+	brainytest.AssertTransition(t, machine, pay, "paying")
+	clock.AdvanceTime(time.Minute)
+	brainytest.AssertState(t, machine, "timed_out")
+	brainytest.AssertTransition(t, machine, pay, "paying")
+	brainytest.AssertTransition(t, machine, ReplayConfirmEventType, "paid")
+
+	_, err := machine.Send(ReplayConfirmEventType)
+	assert.Error(t, err)
+	assert.NoError(t, recorder.Err())
+
+	return log.String()
+}
+
+func TestEventRecorderWritesJSONLines(t *testing.T) {
+	assert := assert.New(t)
+
+	lines := strings.Split(strings.TrimSpace(recordReplayMachineLog(t)), "\n")
+
+	assert.Len(lines, 5)
+	assert.Equal(
+		`{"time":"1970-01-01T00:00:00Z","event":"PAY","payload":{"Event":"PAY","Amount":50},"state":"paying"}`,
+		lines[0],
+	)
+	assert.Contains(lines[1], `"event":"TIMEOUT","state":"timed_out"`)
+	assert.Contains(lines[1], `"time":"1970-01-01T00:01:00Z"`)
+	assert.Contains(lines[4], `"event":"CONFIRM","state":"paid","error":`)
+}
+
+func TestReplayReachesRecordedStates(t *testing.T) {
+	assert := assert.New(t)
+
+	log := recordReplayMachineLog(t)
+
+	definition, err := brainy.NewDefinition(replayMachineConfig(100))
+	assert.NoError(err)
+
+	replayer := brainy.NewReplayer(
+		definition,
+		brainy.WithEventDecoder(ReplayPayEventType, brainy.JSONEventDecoder(ReplayPayEvent{})),
+	)
+
+	machine, err := replayer.Replay(strings.NewReader(log))
+	assert.NoError(err)
+	assert.True(machine.State().Matches(ReplayPaidState))
+}
+
+func TestReplayReportsFirstDivergence(t *testing.T) {
+	assert := assert.New(t)
+
+	log := recordReplayMachineLog(t)
+
+	definition, err := brainy.NewDefinition(replayMachineConfig(10))
+	assert.NoError(err)
+
+	replayer := brainy.NewReplayer(
+		definition,
+		brainy.WithEventDecoder(ReplayPayEventType, brainy.JSONEventDecoder(ReplayPayEvent{})),
+	)
+
+	machine, err := replayer.Replay(strings.NewReader(log))
+
+	var divergence *brainy.ErrReplayDivergence
+	if assert.True(errors.As(err, &divergence)) {
+		assert.Equal(1, divergence.Line)
+		assert.Equal(ReplayPayEventType, divergence.Entry.Event)
+		assert.Equal("idle", divergence.State)
+		assert.ErrorIs(err, brainy.ErrNoTransitionCouldBeRun)
+	}
+	assert.True(machine.State().Matches(ReplayIdleState))
+}
+
+func TestReplayDoesNotRunSideEffects(t *testing.T) {
+	assert := assert.New(t)
+
+	notifications := 0
+	invocations := 0
+	definition, err := brainy.NewDefinition(brainy.StateNode{
+		Initial: OrderCartState,
+		Context: OrderContext{},
+
+		States: brainy.StateNodes{
+			OrderCartState: &brainy.StateNode{
+				On: brainy.Events{
+					OrderCheckoutEvent: brainy.Transition{
+						Target: OrderAwaitingPaymentState,
+						Actions: brainy.Actions{
+							brainy.Assign(func(c brainy.Context, e brainy.Event) brainy.Context {
+								return OrderContext{Items: 1}
+							}),
+							brainy.ActionFn(func(c brainy.Context, e brainy.Event) error {
+								notifications++
+
+								return nil
+							}),
+						},
+					},
+				},
+			},
+
+			OrderAwaitingPaymentState: &brainy.StateNode{
+				Invoke: brainy.Invokes{
+					{
+						ID: "charge",
+						Src: func(ctx context.Context, c brainy.Context, e brainy.Event) (interface{}, error) {
+							invocations++
+							<-ctx.Done()
+
+							return nil, nil
+						},
+					},
+				},
+			},
+		},
+	})
+	assert.NoError(err)
+
+	log := `{"time":"1970-01-01T00:00:00Z","event":"CHECKOUT","state":"awaiting_payment"}`
+
+	machine, err := brainy.NewReplayer(definition).Replay(strings.NewReader(log))
+	assert.NoError(err)
+	defer machine.Stop()
+
+	assert.True(machine.State().Matches(OrderAwaitingPaymentState))
+	assert.Equal(OrderContext{Items: 1}, machine.Context())
+
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(0, notifications)
+	assert.Equal(0, invocations)
+}
+
+func TestReplayRejectsInvalidEventLogs(t *testing.T) {
+	definition, err := brainy.NewDefinition(replayMachineConfig(100))
+	assert.NoError(t, err)
+
+	_, err = brainy.NewReplayer(definition).Replay(strings.NewReader("{\"event\":\"PAY\"\nnot json\n"))
+	assert.ErrorIs(t, err, brainy.ErrInvalidEventLog)
+}
+
+func TestReplayedMachinesHaveTheirOwnContext(t *testing.T) {
+	assert := assert.New(t)
+
+	definition, err := brainy.NewDefinition(brainy.StateNode{
+		Initial: OnState,
+		Context: &CounterContext{},
+
+		States: brainy.StateNodes{
+			OnState: &brainy.StateNode{
+				On: brainy.Events{
+					IncrementEventType: brainy.Transition{
+						Actions: brainy.Actions{
+							brainy.PureActionFn(func(c brainy.Context, e brainy.Event) error {
+								c.(*CounterContext).Count++
+
+								return nil
+							}),
+						},
+					},
+				},
+			},
+		},
+	})
+	assert.NoError(err)
+
+	log := `{"time":"1970-01-01T00:00:00Z","event":"INCREMENT","state":"on"}`
+	replayer := brainy.NewReplayer(definition)
+
+	for i := 0; i < 2; i++ {
+		machine, err := replayer.Replay(strings.NewReader(log))
+		assert.NoError(err)
+		assert.Equal(&CounterContext{Count: 1}, machine.Context())
+	}
+}
@@ -0,0 +1,194 @@
+package brainy
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+const (
+	eventsFileExtension   = ".events.jsonl"
+	snapshotFileExtension = ".snapshot.json"
+)
+
+// A FileEventStore is an EventStore keeping each stream in a directory, as a JSON Lines file of events
+// and a JSON file holding its snapshot.
+//
+// Events are synced to disk before AppendEvent returns, and snapshots are replaced atomically.
+// The directory must only be used by a single FileEventStore at once, as the version of the last event
+// of each stream is cached in memory.
+type FileEventStore struct {
+	directory string
+
+	lock     sync.Mutex
+	versions map[string]int
+}
+
+// NewFileEventStore returns a store keeping streams in the directory, which is created if needed.
+func NewFileEventStore(directory string) (*FileEventStore, error) {
+	if err := os.MkdirAll(directory, 0o755); err != nil {
+		return nil, err
+	}
+
+	return &FileEventStore{
+		directory: directory,
+		versions:  make(map[string]int),
+	}, nil
+}
+
+// streamPath returns the path of a file of the stream. Stream IDs are escaped, so that they can not
+// reference files outside of the directory.
+func (store *FileEventStore) streamPath(streamID string, extension string) string {
+	return filepath.Join(store.directory, url.PathEscape(streamID)+extension)
+}
+
+// AppendEvent appends the event to the events file of the stream.
+func (store *FileEventStore) AppendEvent(streamID string, event PersistedEvent) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	version, ok := store.versions[streamID]
+	if !ok {
+		events, err := store.readEvents(streamID)
+		if err != nil {
+			return err
+		}
+
+		if len(events) > 0 {
+			version = events[len(events)-1].Version
+		}
+	}
+
+	if expectedVersion := version + 1; event.Version != expectedVersion {
+		return fmt.Errorf("%w: expected version %d, got %d", ErrVersionConflict, expectedVersion, event.Version)
+	}
+
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(store.streamPath(streamID, eventsFileExtension), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	store.versions[streamID] = event.Version
+
+	return nil
+}
+
+// LoadEvents returns the events of the stream whose version is greater than afterVersion.
+func (store *FileEventStore) LoadEvents(streamID string, afterVersion int) ([]PersistedEvent, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	events, err := store.readEvents(streamID)
+	if err != nil {
+		return nil, err
+	}
+
+	newerEvents := make([]PersistedEvent, 0, len(events))
+	for _, event := range events {
+		if event.Version > afterVersion {
+			newerEvents = append(newerEvents, event)
+		}
+	}
+
+	return newerEvents, nil
+}
+
+func (store *FileEventStore) readEvents(streamID string) ([]PersistedEvent, error) {
+	file, err := os.Open(store.streamPath(streamID, eventsFileExtension))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	events := make([]PersistedEvent, 0)
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		var event PersistedEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("line %d of the events of the stream: %w", line, err)
+		}
+
+		events = append(events, event)
+	}
+
+	return events, scanner.Err()
+}
+
+// SaveSnapshot replaces the snapshot file of the stream.
+func (store *FileEventStore) SaveSnapshot(streamID string, snapshot PersistedSnapshot) error {
+	content, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	file, err := ioutil.TempFile(store.directory, ".snapshot-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(file.Name(), store.streamPath(streamID, snapshotFileExtension))
+}
+
+// LoadSnapshot returns the snapshot of the stream.
+func (store *FileEventStore) LoadSnapshot(streamID string) (PersistedSnapshot, bool, error) {
+	content, err := ioutil.ReadFile(store.streamPath(streamID, snapshotFileExtension))
+	if errors.Is(err, os.ErrNotExist) {
+		return PersistedSnapshot{}, false, nil
+	}
+	if err != nil {
+		return PersistedSnapshot{}, false, err
+	}
+
+	var snapshot PersistedSnapshot
+	if err := json.Unmarshal(content, &snapshot); err != nil {
+		return PersistedSnapshot{}, false, err
+	}
+
+	return snapshot, true, nil
+}
@@ -0,0 +1,72 @@
+package brainy
+
+import (
+	"fmt"
+	"sync"
+)
+
+// A MemoryEventStore is an EventStore keeping streams in memory. It is mostly useful for tests.
+type MemoryEventStore struct {
+	lock      sync.Mutex
+	events    map[string][]PersistedEvent
+	snapshots map[string]PersistedSnapshot
+}
+
+// NewMemoryEventStore returns an empty store.
+func NewMemoryEventStore() *MemoryEventStore {
+	return &MemoryEventStore{
+		events:    make(map[string][]PersistedEvent),
+		snapshots: make(map[string]PersistedSnapshot),
+	}
+}
+
+// AppendEvent appends the event to the stream.
+func (store *MemoryEventStore) AppendEvent(streamID string, event PersistedEvent) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	events := store.events[streamID]
+	if expectedVersion := len(events) + 1; event.Version != expectedVersion {
+		return fmt.Errorf("%w: expected version %d, got %d", ErrVersionConflict, expectedVersion, event.Version)
+	}
+
+	store.events[streamID] = append(events, event)
+
+	return nil
+}
+
+// LoadEvents returns the events of the stream whose version is greater than afterVersion.
+func (store *MemoryEventStore) LoadEvents(streamID string, afterVersion int) ([]PersistedEvent, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	events := store.events[streamID]
+	if afterVersion >= len(events) {
+		return nil, nil
+	}
+	if afterVersion < 0 {
+		afterVersion = 0
+	}
+
+	return append([]PersistedEvent{}, events[afterVersion:]...), nil
+}
+
+// SaveSnapshot replaces the snapshot of the stream.
+func (store *MemoryEventStore) SaveSnapshot(streamID string, snapshot PersistedSnapshot) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.snapshots[streamID] = snapshot
+
+	return nil
+}
+
+// LoadSnapshot returns the snapshot of the stream.
+func (store *MemoryEventStore) LoadSnapshot(streamID string) (PersistedSnapshot, bool, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	snapshot, ok := store.snapshots[streamID]
+
+	return snapshot, ok, nil
+}
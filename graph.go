@@ -0,0 +1,110 @@
+package brainy
+
+// A Graph describes the structure of a definition: its state nodes and the transitions between them.
+// Actions, guards and services are described by their names only, so that a Graph can be serialized
+// to JSON and analyzed outside of the program that built the definition, as Lint does.
+type Graph struct {
+	Root GraphStateNode `json:"root"`
+}
+
+// A GraphStateNode describes a state node of a Graph.
+// ID is the full path of the state node from the root state node, such as `(machine).checkout.payment`.
+type GraphStateNode struct {
+	ID      string   `json:"id"`
+	Key     string   `json:"key"`
+	Initial string   `json:"initial,omitempty"`
+	Final   bool     `json:"final,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+
+	// Transitions holds the transitions of the event handlers of the state node, sorted by event type.
+	// The transitions of an event keep their order.
+	Transitions []GraphTransition `json:"transitions,omitempty"`
+	// Choice holds the transitions of a choice state node.
+	Choice []GraphTransition `json:"choice,omitempty"`
+	// Junction holds the transitions of a junction state node.
+	Junction []GraphTransition `json:"junction,omitempty"`
+
+	// States holds the children state nodes, in document order.
+	States []GraphStateNode `json:"states,omitempty"`
+}
+
+// A GraphTransition describes a transition of a Graph.
+// Target is the full path of the targeted state node, and is empty for targetless transitions.
+// Choice and junction transitions do not have an Event.
+type GraphTransition struct {
+	Event     string `json:"event,omitempty"`
+	Target    string `json:"target,omitempty"`
+	Guard     string `json:"guard,omitempty"`
+	External  bool   `json:"external,omitempty"`
+	Forbidden bool   `json:"forbidden,omitempty"`
+}
+
+// Graph returns the structure of the definition.
+func (definition *Definition) Graph() Graph {
+	return Graph{
+		Root: definition.root.graphStateNode(),
+	}
+}
+
+func (s *StateNode) graphStateNode() GraphStateNode {
+	node := GraphStateNode{
+		ID:      s.id,
+		Key:     string(s.key),
+		Initial: string(s.Initial),
+		Final:   s.Final,
+		Tags:    s.Tags,
+	}
+
+	for _, eventType := range s.On.sortedEventTypes() {
+		eventHandler := s.On[eventType]
+
+		if isForbiddenTransition(eventHandler) {
+			node.Transitions = append(node.Transitions, GraphTransition{
+				Event:     string(eventType),
+				Forbidden: true,
+			})
+			continue
+		}
+
+		for _, transition := range eventHandler.transitions() {
+			graphTransition := s.graphTransition(transition)
+			graphTransition.Event = string(eventType)
+
+			node.Transitions = append(node.Transitions, graphTransition)
+		}
+	}
+
+	for _, transition := range s.Choice {
+		node.Choice = append(node.Choice, s.graphTransition(transition))
+	}
+
+	for _, transition := range s.Junction {
+		node.Junction = append(node.Junction, s.graphTransition(transition))
+	}
+
+	for _, stateNode := range s.childrenInDocumentOrder {
+		node.States = append(node.States, stateNode.graphStateNode())
+	}
+
+	return node
+}
+
+// graphTransition describes a transition handled by the state node.
+// Definitions are validated, so the target of the transition can always be resolved.
+func (s *StateNode) graphTransition(transition Transition) GraphTransition {
+	graphTransition := GraphTransition{
+		External: transition.External,
+	}
+
+	if guard := transition.guard(); guard != nil {
+		graphTransition.Guard = guard.String()
+	}
+
+	if !transition.isTargetBlank() {
+		if targetedStateNode, err := resolveTransitionTarget(s, transition); err == nil {
+			graphTransition.Target = targetedStateNode.id
+		}
+	}
+
+	return graphTransition
+}
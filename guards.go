@@ -0,0 +1,179 @@
+package brainy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrTransitionWithCondAndGuard is returned when a transition defines both a Cond and a Guard.
+	// Only one of them can be used to decide whether the transition can be taken.
+	ErrTransitionWithCondAndGuard = errors.New("transition can not have both a cond and a guard")
+	// ErrNilGuard is returned when a guard, or one of the guards it combines, is nil,
+	// such as a NamedGuard without Cond or Not(nil).
+	ErrNilGuard = errors.New("guard is nil")
+)
+
+// A Guard decides whether a transition can be taken.
+// Cond functions are anonymous guards. NamedGuard, And, Or, Not and In are
+// guards that can be identified, thanks to their String method.
+type Guard interface {
+	// check takes the current state node of the state machine in addition to the context
+	// and the event, so that guards can depend on the state the state machine is in.
+	check(current *StateNode, c Context, e Event) bool
+	String() string
+}
+
+func (cond Cond) check(current *StateNode, c Context, e Event) bool {
+	return cond(c, e)
+}
+
+func (cond Cond) String() string {
+	return "anonymous"
+}
+
+// NamedGuard is a Cond function with an identifier.
+// The identifier is used in error messages and when a state machine definition is described.
+//
+//  isAdmin := NamedGuard{
+//  	Name: "isAdmin",
+//  	Cond: func(c Context, e Event) bool {
+//  		return c.(*UserContext).Role == "admin"
+//  	},
+//  }
+type NamedGuard struct {
+	Name string
+	Cond Cond
+}
+
+func (g NamedGuard) check(current *StateNode, c Context, e Event) bool {
+	return g.Cond(c, e)
+}
+
+func (g NamedGuard) String() string {
+	return g.Name
+}
+
+type andGuard []Guard
+
+func (g andGuard) check(current *StateNode, c Context, e Event) bool {
+	for _, guard := range g {
+		if !guard.check(current, c, e) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (g andGuard) String() string {
+	return "and(" + joinGuards(g) + ")"
+}
+
+// And returns a guard that is true when all guards are true.
+// Guards are evaluated in order, and the evaluation stops at the first false guard.
+func And(guards ...Guard) Guard {
+	return andGuard(guards)
+}
+
+type orGuard []Guard
+
+func (g orGuard) check(current *StateNode, c Context, e Event) bool {
+	for _, guard := range g {
+		if guard.check(current, c, e) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (g orGuard) String() string {
+	return "or(" + joinGuards(g) + ")"
+}
+
+// Or returns a guard that is true when at least one of the guards is true.
+// Guards are evaluated in order, and the evaluation stops at the first true guard.
+func Or(guards ...Guard) Guard {
+	return orGuard(guards)
+}
+
+type notGuard struct {
+	Guard Guard
+}
+
+func (g notGuard) check(current *StateNode, c Context, e Event) bool {
+	return !g.Guard.check(current, c, e)
+}
+
+func (g notGuard) String() string {
+	return "not(" + g.Guard.String() + ")"
+}
+
+// Not returns a guard that is true when guard is false.
+func Not(guard Guard) Guard {
+	return notGuard{
+		Guard: guard,
+	}
+}
+
+type inGuard []StateType
+
+func (g inGuard) check(current *StateNode, c Context, e Event) bool {
+	return current.Matches(g...)
+}
+
+func (g inGuard) String() string {
+	return "in(" + joinStateTypes(g...) + ")"
+}
+
+// In returns a guard that is true when the state machine is in the state described by the selectors.
+// Selectors are interpreted the same way as by StateNode.Matches.
+func In(stateSelectors ...StateType) Guard {
+	return inGuard(stateSelectors)
+}
+
+// validateGuard returns an error if the guard or one of the guards it combines can not be checked.
+func validateGuard(guard Guard) error {
+	switch g := guard.(type) {
+	case nil:
+		return ErrNilGuard
+	case Cond:
+		if g == nil {
+			return ErrNilGuard
+		}
+	case NamedGuard:
+		if g.Cond == nil {
+			return fmt.Errorf("%w: %q has no cond", ErrNilGuard, g.Name)
+		}
+	case andGuard:
+		return validateCombinedGuards("and", g)
+	case orGuard:
+		return validateCombinedGuards("or", g)
+	case notGuard:
+		return validateCombinedGuards("not", []Guard{g.Guard})
+	}
+
+	return nil
+}
+
+func validateCombinedGuards(operator string, guards []Guard) error {
+	for index, guard := range guards {
+		if err := validateGuard(guard); err != nil {
+			return fmt.Errorf("%w, in operand %d of %s", err, index, operator)
+		}
+	}
+
+	return nil
+}
+
+func joinGuards(guards []Guard) string {
+	guardsAsStrings := make([]string, 0, len(guards))
+
+	for _, guard := range guards {
+		guardsAsStrings = append(guardsAsStrings, guard.String())
+	}
+
+	return strings.Join(guardsAsStrings, ", ")
+}
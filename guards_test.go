@@ -0,0 +1,196 @@
+package brainy_test
+
+import (
+	"testing"
+
+	"github.com/Devessier/brainy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuardCombinators(t *testing.T) {
+	alwaysTrue := brainy.NamedGuard{
+		Name: "alwaysTrue",
+		Cond: func(c brainy.Context, e brainy.Event) bool {
+			return true
+		},
+	}
+	alwaysFalse := brainy.NamedGuard{
+		Name: "alwaysFalse",
+		Cond: func(c brainy.Context, e brainy.Event) bool {
+			return false
+		},
+	}
+
+	testCases := []struct {
+		Name          string
+		Guard         brainy.Guard
+		ShouldBeTaken bool
+		Description   string
+	}{
+		{
+			Name:          "and with a false guard",
+			Guard:         brainy.And(alwaysTrue, alwaysFalse),
+			ShouldBeTaken: false,
+			Description:   "and(alwaysTrue, alwaysFalse)",
+		},
+		{
+			Name:          "or with a true guard",
+			Guard:         brainy.Or(alwaysFalse, alwaysTrue),
+			ShouldBeTaken: true,
+			Description:   "or(alwaysFalse, alwaysTrue)",
+		},
+		{
+			Name:          "not",
+			Guard:         brainy.Not(alwaysFalse),
+			ShouldBeTaken: true,
+			Description:   "not(alwaysFalse)",
+		},
+		{
+			Name:          "in current state",
+			Guard:         brainy.In(OffState),
+			ShouldBeTaken: true,
+			Description:   "in(off)",
+		},
+		{
+			Name:          "in another state",
+			Guard:         brainy.Not(brainy.In(OffState)),
+			ShouldBeTaken: false,
+			Description:   "not(in(off))",
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			stateMachine, err := brainy.NewMachine(brainy.StateNode{
+				Initial: OffState,
+
+				States: brainy.StateNodes{
+					OffState: &brainy.StateNode{
+						On: brainy.Events{
+							OnEvent: brainy.Transition{
+								Guard:  testCase.Guard,
+								Target: OnState,
+							},
+						},
+					},
+
+					OnState: &brainy.StateNode{},
+				},
+			})
+			assert.NoError(err)
+			assert.Equal(testCase.Description, testCase.Guard.String())
+
+			_, err = stateMachine.Send(OnEvent)
+			if testCase.ShouldBeTaken {
+				assert.NoError(err)
+				assert.True(stateMachine.Current().Matches(OnState))
+				return
+			}
+
+			assert.ErrorIs(err, brainy.ErrNoTransitionCouldBeRun)
+
+			var detailedErr *brainy.ErrNoTransitionCouldBeRunWithDetails
+			assert.ErrorAs(err, &detailedErr)
+			assert.Equal([]string{testCase.Description}, detailedErr.Guards)
+			assert.True(stateMachine.Current().Matches(OffState))
+		})
+	}
+}
+
+func TestTransitionCanNotHaveCondAndGuard(t *testing.T) {
+	assert := assert.New(t)
+
+	stateMachine, err := brainy.NewMachine(brainy.StateNode{
+		Initial: OffState,
+
+		States: brainy.StateNodes{
+			OffState: &brainy.StateNode{
+				On: brainy.Events{
+					OnEvent: brainy.Transition{
+						Cond: func(c brainy.Context, e brainy.Event) bool {
+							return true
+						},
+						Guard:  brainy.In(OffState),
+						Target: OnState,
+					},
+				},
+			},
+
+			OnState: &brainy.StateNode{},
+		},
+	})
+	assert.Nil(stateMachine)
+	assert.ErrorIs(err, brainy.ErrTransitionWithCondAndGuard)
+}
+
+func TestNilGuardsAreInvalid(t *testing.T) {
+	isReady := func(c brainy.Context, e brainy.Event) bool {
+		return true
+	}
+
+	testCases := []struct {
+		Name            string
+		Guard           brainy.Guard
+		ExpectedMessage string
+	}{
+		{
+			Name:            "named guard without cond",
+			Guard:           brainy.NamedGuard{Name: "isReady"},
+			ExpectedMessage: `guard is nil: "isReady" has no cond`,
+		},
+		{
+			Name:            "not nil",
+			Guard:           brainy.Not(nil),
+			ExpectedMessage: "guard is nil, in operand 0 of not",
+		},
+		{
+			Name:            "nested nil guard",
+			Guard:           brainy.And(brainy.Cond(isReady), brainy.Or(brainy.In(OffState), brainy.NamedGuard{Name: "isSet"})),
+			ExpectedMessage: `guard is nil: "isSet" has no cond, in operand 1 of or, in operand 1 of and`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			_, err := brainy.NewDefinition(brainy.StateNode{
+				Initial: OffState,
+
+				States: brainy.StateNodes{
+					OffState: &brainy.StateNode{
+						On: brainy.Events{
+							OnEvent: brainy.Transition{
+								Guard:  testCase.Guard,
+								Target: OnState,
+							},
+						},
+					},
+
+					RouteState: &brainy.StateNode{
+						Choice: brainy.Transitions{
+							{
+								Guard:  testCase.Guard,
+								Target: OnState,
+							},
+							{
+								Target: OffState,
+							},
+						},
+					},
+
+					OnState: &brainy.StateNode{},
+				},
+			})
+			assert.ErrorIs(err, brainy.ErrNilGuard)
+
+			var invalidDefinition *brainy.ErrInvalidDefinition
+			if assert.ErrorAs(err, &invalidDefinition) && assert.Len(invalidDefinition.Errors, 2) {
+				assert.Equal(`(machine).off: `+testCase.ExpectedMessage+`, in a transition of on`, invalidDefinition.Errors[0].Error())
+				assert.Equal(`(machine).route: `+testCase.ExpectedMessage+`, in a choice transition of (machine).route`, invalidDefinition.Errors[1].Error())
+			}
+		})
+	}
+}
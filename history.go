@@ -0,0 +1,199 @@
+package brainy
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned when travelling through the history of a state machine.
+var (
+	// ErrHistoryDisabled is returned when the history of a state machine built without WithHistory is used.
+	ErrHistoryDisabled = errors.New("history is disabled")
+	// ErrStepNotInHistory is returned when a step has never been reached or has been dropped from the history.
+	ErrStepNotInHistory = errors.New("step is not in history")
+)
+
+// A HistoryStep is a state reached by a state machine.
+// Steps are numbered from 0, the state reached by the initial transition, and each event sent to
+// the state machine adds a step.
+type HistoryStep struct {
+	Index int
+	State State
+}
+
+// history keeps the last steps of a state machine, and the step the state machine is at.
+type history struct {
+	size   int
+	steps  []HistoryStep
+	cursor int
+}
+
+// WithHistory keeps the last size states of the state machine, with their context and the event that
+// lead to them, so that the state machine can travel back to them or be forked from them.
+// The size must be positive.
+//
+// Contexts are kept as they are: a context modified in place, instead of being replaced by Assign actions,
+// modifies the steps that hold it.
+func WithHistory(size int) MachineOption {
+	if size < 1 {
+		size = 1
+	}
+
+	return func(machine *Machine) {
+		machine.history = &history{
+			size: size,
+		}
+	}
+}
+
+// recordStep adds the state after the step the state machine is at. Steps that were travelled back from
+// are discarded, as the state machine leaves them for a new timeline.
+// It can be called on a nil history.
+func (h *history) recordStep(state State) {
+	if h == nil {
+		return
+	}
+
+	index := 0
+	if len(h.steps) > 0 {
+		index = h.steps[h.cursor].Index + 1
+		h.steps = h.steps[:h.cursor+1]
+	}
+
+	h.steps = append(h.steps, HistoryStep{
+		Index: index,
+		State: state,
+	})
+	if len(h.steps) > h.size {
+		h.steps = append([]HistoryStep{}, h.steps[len(h.steps)-h.size:]...)
+	}
+
+	h.cursor = len(h.steps) - 1
+}
+
+func (h *history) position(index int) (int, error) {
+	if h == nil {
+		return 0, ErrHistoryDisabled
+	}
+
+	if len(h.steps) == 0 || index < h.steps[0].Index || index > h.steps[len(h.steps)-1].Index {
+		return 0, fmt.Errorf("%w: step %d", ErrStepNotInHistory, index)
+	}
+
+	return index - h.steps[0].Index, nil
+}
+
+// History returns the steps kept in the history, from the oldest one, or nil if the history is disabled.
+func (machine *Machine) History() []HistoryStep {
+	if !machine.disableLocking {
+		machine.lock.Lock()
+		defer machine.lock.Unlock()
+	}
+
+	if machine.history == nil {
+		return nil
+	}
+
+	return append([]HistoryStep{}, machine.history.steps...)
+}
+
+// CurrentStep returns the step the state machine is at.
+func (machine *Machine) CurrentStep() (HistoryStep, error) {
+	if !machine.disableLocking {
+		machine.lock.Lock()
+		defer machine.lock.Unlock()
+	}
+
+	if machine.history == nil {
+		return HistoryStep{}, ErrHistoryDisabled
+	}
+
+	return machine.history.steps[machine.history.cursor], nil
+}
+
+// StepBackward restores the state machine to the step before the one it is at.
+// See JumpToStep.
+func (machine *Machine) StepBackward() (State, error) {
+	return machine.travel(-1)
+}
+
+// StepForward restores the state machine to the step after the one it is at, if it travelled back
+// and no event has been sent since then.
+// See JumpToStep.
+func (machine *Machine) StepForward() (State, error) {
+	return machine.travel(1)
+}
+
+// JumpToStep restores the state machine to the step of the history whose index is given.
+//
+// No action is run: the state machine is restored as by NewMachineFromSnapshot. Its running services, delayed events
+// and children state machines are stopped, and the services invoked by the restored state nodes are started.
+// Children are not restored: children referenced by the restored context stay stopped, and will not accept events.
+// The steps after the restored one are kept, so that the state machine can step forward, until an event is sent:
+// the event then starts a new timeline from the restored step.
+func (machine *Machine) JumpToStep(index int) (State, error) {
+	if !machine.disableLocking {
+		machine.lock.Lock()
+		defer machine.lock.Unlock()
+	}
+
+	return machine.jumpToStep(index)
+}
+
+func (machine *Machine) travel(offset int) (State, error) {
+	if !machine.disableLocking {
+		machine.lock.Lock()
+		defer machine.lock.Unlock()
+	}
+
+	if machine.history == nil {
+		return machine.state, ErrHistoryDisabled
+	}
+
+	return machine.jumpToStep(machine.history.steps[machine.history.cursor].Index + offset)
+}
+
+func (machine *Machine) jumpToStep(index int) (State, error) {
+	if machine.stopped {
+		return machine.state, ErrMachineStopped
+	}
+
+	position, err := machine.history.position(index)
+	if err != nil {
+		return machine.state, err
+	}
+
+	state := machine.history.steps[position].State
+	machine.restore(state.StateNode, state.Context, state.Event)
+	machine.history.cursor = position
+
+	return machine.state, nil
+}
+
+// Fork returns a new state machine restored from the step of the history whose index is given,
+// as by NewMachineFromSnapshot. The state machine being forked is left untouched.
+// Children are not forked: children referenced by the restored context are the ones of the forked state machine,
+// and are not stopped.
+// The options of the forked state machine are not inherited: they must be given again.
+func (machine *Machine) Fork(index int, options ...MachineOption) (*Machine, error) {
+	snapshot, err := machine.stepSnapshot(index)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewMachineFromSnapshot(machine.definition, snapshot, options...)
+}
+
+func (machine *Machine) stepSnapshot(index int) (Snapshot, error) {
+	if !machine.disableLocking {
+		machine.lock.Lock()
+		defer machine.lock.Unlock()
+	}
+
+	position, err := machine.history.position(index)
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	return machine.history.steps[position].State.Snapshot(), nil
+}
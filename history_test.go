@@ -0,0 +1,236 @@
+package brainy_test
+
+import (
+	"testing"
+
+	"github.com/Devessier/brainy"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	OrderCartState            brainy.StateType = "cart"
+	OrderAwaitingPaymentState brainy.StateType = "awaiting_payment"
+	OrderPaidState            brainy.StateType = "paid"
+
+	OrderAddItemEvent  brainy.EventType = "ADD_ITEM"
+	OrderCheckoutEvent brainy.EventType = "CHECKOUT"
+	OrderPayEvent      brainy.EventType = "PAY"
+)
+
+type OrderContext struct {
+	Items int
+}
+
+func orderMachineConfig() brainy.StateNode {
+	return brainy.StateNode{
+		Initial: OrderCartState,
+		Context: OrderContext{},
+
+		States: brainy.StateNodes{
+			OrderCartState: &brainy.StateNode{
+				On: brainy.Events{
+					OrderAddItemEvent: brainy.Transition{
+						Actions: brainy.Actions{
+							brainy.Assign(func(c brainy.Context, e brainy.Event) brainy.Context {
+								return OrderContext{Items: c.(OrderContext).Items + 1}
+							}),
+						},
+					},
+					OrderCheckoutEvent: OrderAwaitingPaymentState,
+				},
+			},
+
+			OrderAwaitingPaymentState: &brainy.StateNode{
+				On: brainy.Events{
+					OrderPayEvent: OrderPaidState,
+				},
+			},
+
+			OrderPaidState: &brainy.StateNode{},
+		},
+	}
+}
+
+func TestHistoryTravelsBackwardAndForward(t *testing.T) {
+	assert := assert.New(t)
+
+	machine, err := brainy.NewMachine(orderMachineConfig(), brainy.WithHistory(10))
+	assert.NoError(err)
+
+	for _, event := range []brainy.EventType{OrderAddItemEvent, OrderAddItemEvent, OrderCheckoutEvent} {
+		_, err := machine.Send(event)
+		assert.NoError(err)
+	}
+
+	steps := machine.History()
+	assert.Len(steps, 4)
+	assert.Equal(0, steps[0].Index)
+	assert.Equal(brainy.InitialTransitionEventType, steps[0].State.Event)
+	assert.Equal(OrderCheckoutEvent, steps[3].State.Event)
+
+	state, err := machine.StepBackward()
+	assert.NoError(err)
+	assert.True(state.Matches(OrderCartState))
+	assert.Equal(OrderContext{Items: 2}, state.Context)
+	assert.Equal(OrderContext{Items: 2}, machine.Context())
+
+	state, err = machine.JumpToStep(1)
+	assert.NoError(err)
+	assert.Equal(OrderContext{Items: 1}, state.Context)
+
+	state, err = machine.StepForward()
+	assert.NoError(err)
+	assert.Equal(OrderContext{Items: 2}, state.Context)
+
+	currentStep, err := machine.CurrentStep()
+	assert.NoError(err)
+	assert.Equal(2, currentStep.Index)
+
+	_, err = machine.JumpToStep(4)
+	assert.ErrorIs(err, brainy.ErrStepNotInHistory)
+
+	// Sending an event from a past step starts a new timeline.
+	state, err = machine.Send(OrderAddItemEvent)
+	assert.NoError(err)
+	assert.Equal(OrderContext{Items: 3}, state.Context)
+
+	steps = machine.History()
+	assert.Len(steps, 4)
+	assert.Equal(3, steps[3].Index)
+	assert.Equal(OrderAddItemEvent, steps[3].State.Event)
+
+	_, err = machine.StepForward()
+	assert.ErrorIs(err, brainy.ErrStepNotInHistory)
+}
+
+func TestHistoryIsBounded(t *testing.T) {
+	assert := assert.New(t)
+
+	machine, err := brainy.NewMachine(orderMachineConfig(), brainy.WithHistory(2))
+	assert.NoError(err)
+
+	for _, event := range []brainy.EventType{OrderAddItemEvent, OrderCheckoutEvent, OrderPayEvent} {
+		_, err := machine.Send(event)
+		assert.NoError(err)
+	}
+
+	steps := machine.History()
+	if assert.Len(steps, 2) {
+		assert.Equal(2, steps[0].Index)
+		assert.Equal(3, steps[1].Index)
+	}
+
+	_, err = machine.JumpToStep(1)
+	assert.ErrorIs(err, brainy.ErrStepNotInHistory)
+
+	state, err := machine.StepBackward()
+	assert.NoError(err)
+	assert.True(state.Matches(OrderAwaitingPaymentState))
+}
+
+func TestHistoryIsDisabledByDefault(t *testing.T) {
+	assert := assert.New(t)
+
+	machine, err := brainy.NewMachine(orderMachineConfig())
+	assert.NoError(err)
+
+	assert.Nil(machine.History())
+
+	_, err = machine.StepBackward()
+	assert.ErrorIs(err, brainy.ErrHistoryDisabled)
+
+	_, err = machine.Fork(0)
+	assert.ErrorIs(err, brainy.ErrHistoryDisabled)
+}
+
+func TestForkRestoresPastStep(t *testing.T) {
+	assert := assert.New(t)
+
+	machine, err := brainy.NewMachine(orderMachineConfig(), brainy.WithHistory(10))
+	assert.NoError(err)
+
+	for _, event := range []brainy.EventType{OrderAddItemEvent, OrderCheckoutEvent, OrderPayEvent} {
+		_, err := machine.Send(event)
+		assert.NoError(err)
+	}
+
+	fork, err := machine.Fork(2, brainy.WithHistory(10))
+	assert.NoError(err)
+
+	assert.True(fork.State().Matches(OrderAwaitingPaymentState))
+	assert.Equal(OrderContext{Items: 1}, fork.Context())
+	assert.Len(fork.History(), 1)
+
+	state, err := fork.Send(OrderPayEvent)
+	assert.NoError(err)
+	assert.True(state.Matches(OrderPaidState))
+
+	// The forked state machine is left untouched.
+	assert.True(machine.State().Matches(OrderPaidState))
+	assert.Len(machine.History(), 4)
+}
+
+func TestNewMachineFromSnapshot(t *testing.T) {
+	assert := assert.New(t)
+
+	definition, err := brainy.NewDefinition(orderMachineConfig())
+	assert.NoError(err)
+
+	machine, err := brainy.NewMachineFromSnapshot(definition, brainy.Snapshot{
+		Value:   brainy.StateValue{OrderAwaitingPaymentState: nil},
+		Context: OrderContext{Items: 4},
+		Event:   OrderCheckoutEvent,
+	})
+	assert.NoError(err)
+
+	assert.Equal(OrderContext{Items: 4}, machine.Context())
+	assert.Equal(brainy.Snapshot{
+		Value:   brainy.StateValue{OrderAwaitingPaymentState: nil},
+		Context: OrderContext{Items: 4},
+		Event:   OrderCheckoutEvent,
+	}, machine.Snapshot())
+
+	_, err = brainy.NewMachineFromSnapshot(definition, brainy.Snapshot{
+		Value: brainy.StateValue{"unknown": nil},
+	})
+	assert.ErrorIs(err, brainy.ErrInvalidSnapshot)
+}
+
+func TestJumpToStepStopsChildrenWithoutRestoringThem(t *testing.T) {
+	assert := assert.New(t)
+
+	sessionContext := &SessionContext{}
+	machine, err := brainy.NewMachine(brainy.StateNode{
+		Context: sessionContext,
+
+		Initial: SupervisingState,
+
+		States: brainy.StateNodes{
+			SupervisingState: &brainy.StateNode{
+				OnEntry: brainy.Actions{
+					brainy.Spawn(newRequestMachine, func(c brainy.Context, e brainy.Event, child *brainy.Machine) {
+						c.(*SessionContext).Request = child
+					}),
+				},
+
+				On: brainy.Events{
+					PingEvent: brainy.Transition{},
+				},
+			},
+		},
+	}, brainy.WithHistory(10))
+	assert.NoError(err)
+
+	child := sessionContext.Request
+	assert.NotNil(child)
+
+	_, err = machine.Send(PingEvent)
+	assert.NoError(err)
+
+	_, err = machine.JumpToStep(0)
+	assert.NoError(err)
+
+	assert.Same(child, machine.Context().(*SessionContext).Request)
+	_, err = child.Send(PingEvent)
+	assert.ErrorIs(err, brainy.ErrMachineStopped)
+}
@@ -0,0 +1,175 @@
+package brainy
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrBlankInvokeID is returned when an invoked service of a state node does not have an identifier.
+// The identifier is required to build the type of the events sent when the service is done or has failed.
+var ErrBlankInvokeID = errors.New("expected an id for invoked service")
+
+// ErrMissingInvokeSrc is returned when an invoked service of a state node does not have a Src function.
+var ErrMissingInvokeSrc = errors.New("expected a src function for invoked service")
+
+const (
+	doneInvokeEventTypePrefix    = "done.invoke."
+	errorPlatformEventTypePrefix = "error.platform."
+)
+
+// DoneInvokeEventType returns the type of the event sent to the state machine
+// when the invoked service identified by id has returned successfully.
+func DoneInvokeEventType(id string) EventType {
+	return EventType(doneInvokeEventTypePrefix + id)
+}
+
+// ErrorPlatformEventType returns the type of the event sent to the state machine
+// when the invoked service identified by id has returned an error.
+func ErrorPlatformEventType(id string) EventType {
+	return EventType(errorPlatformEventTypePrefix + id)
+}
+
+// DoneInvokeEvent is sent to the state machine when an invoked service returned without error.
+// Data holds the value returned by the service.
+type DoneInvokeEvent struct {
+	EventWithType
+	ID   string
+	Data interface{}
+}
+
+// ErrorPlatformEvent is sent to the state machine when an invoked service returned an error.
+type ErrorPlatformEvent struct {
+	EventWithType
+	ID  string
+	Err error
+}
+
+// A Service is a function started in its own goroutine when the state node that invokes it is entered.
+// It takes a context.Context that is cancelled when the state node is exited or when the state machine
+// is stopped, the state machine context and the event that lead to the state node being entered.
+//
+// The value returned by the service is sent to the state machine as a DoneInvokeEvent,
+// and the error as an ErrorPlatformEvent. Nothing is sent if the service has been cancelled.
+type Service func(ctx context.Context, c Context, e Event) (interface{}, error)
+
+// Invoke describes a service to start when a state node is entered and to cancel when it is exited.
+type Invoke struct {
+	// ID identifies the service. It is used to build the type of the events the service sends:
+	// done.invoke.<id> and error.platform.<id>.
+	ID  string
+	Src Service
+}
+
+// Invokes is a slice of Invoke.
+type Invokes []Invoke
+
+// invocation is a running instance of an invoked service.
+type invocation struct {
+	id     string
+	cancel context.CancelFunc
+}
+
+func (s *StateNode) validateInvokes() error {
+	for _, invoke := range s.Invoke {
+		if invoke.ID == "" {
+			return ErrBlankInvokeID
+		}
+
+		if invoke.Src == nil {
+			return ErrMissingInvokeSrc
+		}
+	}
+
+	return nil
+}
+
+// startInvocations starts in their own goroutine all services invoked by the state node.
+// Services are not invoked while side effects are suppressed.
+func (machine *Machine) startInvocations(stateNode *StateNode, c Context, e Event) {
+	if machine.suppressSideEffects {
+		return
+	}
+
+	for _, invoke := range stateNode.Invoke {
+		ctx, cancel := context.WithCancel(context.Background())
+		runningInvocation := &invocation{
+			id:     invoke.ID,
+			cancel: cancel,
+		}
+
+		machine.invocations[stateNode] = append(machine.invocations[stateNode], runningInvocation)
+
+		go func(src Service) {
+			data, err := src(ctx, c, e)
+
+			// The result of a cancelled service is discarded.
+			if ctx.Err() != nil {
+				return
+			}
+
+			if err != nil {
+				machine.sendFromInvocation(runningInvocation, ErrorPlatformEvent{
+					EventWithType: EventWithType{
+						Event: ErrorPlatformEventType(runningInvocation.id),
+					},
+					ID:  runningInvocation.id,
+					Err: err,
+				})
+				return
+			}
+
+			machine.sendFromInvocation(runningInvocation, DoneInvokeEvent{
+				EventWithType: EventWithType{
+					Event: DoneInvokeEventType(runningInvocation.id),
+				},
+				ID:   runningInvocation.id,
+				Data: data,
+			})
+		}(invoke.Src)
+	}
+}
+
+// cancelInvocations cancels all running services invoked by the state node.
+func (machine *Machine) cancelInvocations(stateNode *StateNode) {
+	for _, runningInvocation := range machine.invocations[stateNode] {
+		runningInvocation.cancel()
+	}
+
+	delete(machine.invocations, stateNode)
+}
+
+func (machine *Machine) isInvocationRunning(runningInvocation *invocation) bool {
+	for _, invocations := range machine.invocations {
+		for _, invocationToCompare := range invocations {
+			if invocationToCompare == runningInvocation {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// sendFromInvocation sends the event to the state machine if the invocation is still running.
+// The invocation may have been cancelled while the service was returning, in which
+// case the event must not be sent.
+//
+// Errors occuring while the event is processed are not reported, as there is no caller to report them to.
+func (machine *Machine) sendFromInvocation(runningInvocation *invocation, event Event) {
+	deliverMessages(machine.lockAndSendFromInvocation(runningInvocation, event))
+}
+
+func (machine *Machine) lockAndSendFromInvocation(runningInvocation *invocation, event Event) []message {
+	if !machine.disableLocking {
+		machine.lock.Lock()
+		defer machine.lock.Unlock()
+	}
+
+	if machine.stopped || !machine.isInvocationRunning(runningInvocation) {
+		return nil
+	}
+
+	machine.send(event)
+
+	return machine.takeOutbox()
+}
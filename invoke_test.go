@@ -0,0 +1,176 @@
+package brainy_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Devessier/brainy"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	FetchingState brainy.StateType = "fetching"
+	SuccessState  brainy.StateType = "success"
+	FailureState  brainy.StateType = "failure"
+
+	CancelEvent brainy.EventType = "CANCEL"
+)
+
+func newFetchingMachine(t *testing.T, service brainy.Service, reached chan<- brainy.Event) *brainy.Machine {
+	notify := brainy.ActionFn(func(c brainy.Context, e brainy.Event) error {
+		reached <- e
+		return nil
+	})
+
+	stateMachine, err := brainy.NewMachine(brainy.StateNode{
+		Initial: FetchingState,
+
+		States: brainy.StateNodes{
+			FetchingState: &brainy.StateNode{
+				Invoke: brainy.Invokes{
+					{
+						ID:  "fetch",
+						Src: service,
+					},
+				},
+
+				On: brainy.Events{
+					brainy.DoneInvokeEventType("fetch"):    SuccessState,
+					brainy.ErrorPlatformEventType("fetch"): FailureState,
+					CancelEvent:                            OffState,
+				},
+			},
+
+			SuccessState: &brainy.StateNode{
+				OnEntry: brainy.Actions{notify},
+			},
+
+			FailureState: &brainy.StateNode{
+				OnEntry: brainy.Actions{notify},
+			},
+
+			OffState: &brainy.StateNode{},
+		},
+	})
+	assert.NoError(t, err)
+
+	return stateMachine
+}
+
+func waitForEvent(t *testing.T, events <-chan brainy.Event) brainy.Event {
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the state machine")
+		return nil
+	}
+}
+
+func TestInvokedServiceSendsDoneEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	reached := make(chan brainy.Event, 1)
+	stateMachine := newFetchingMachine(t, func(ctx context.Context, c brainy.Context, e brainy.Event) (interface{}, error) {
+		return 42, nil
+	}, reached)
+
+	event := waitForEvent(t, reached)
+	doneEvent, ok := event.(brainy.DoneInvokeEvent)
+	assert.True(ok)
+	assert.Equal("fetch", doneEvent.ID)
+	assert.Equal(42, doneEvent.Data)
+	assert.True(stateMachine.Current().Matches(SuccessState))
+}
+
+func TestInvokedServiceSendsErrorEvent(t *testing.T) {
+	assert := assert.New(t)
+
+	serviceErr := errors.New("fetch failed")
+
+	reached := make(chan brainy.Event, 1)
+	stateMachine := newFetchingMachine(t, func(ctx context.Context, c brainy.Context, e brainy.Event) (interface{}, error) {
+		return nil, serviceErr
+	}, reached)
+
+	event := waitForEvent(t, reached)
+	errorEvent, ok := event.(brainy.ErrorPlatformEvent)
+	assert.True(ok)
+	assert.Equal("fetch", errorEvent.ID)
+	assert.ErrorIs(errorEvent.Err, serviceErr)
+	assert.True(stateMachine.Current().Matches(FailureState))
+}
+
+func TestInvokedServiceIsCancelledOnExit(t *testing.T) {
+	assert := assert.New(t)
+
+	cancelled := make(chan struct{})
+	reached := make(chan brainy.Event, 1)
+	stateMachine := newFetchingMachine(t, func(ctx context.Context, c brainy.Context, e brainy.Event) (interface{}, error) {
+		<-ctx.Done()
+		close(cancelled)
+
+		return nil, ctx.Err()
+	}, reached)
+
+	_, err := stateMachine.Send(CancelEvent)
+	assert.NoError(err)
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("service has not been cancelled")
+	}
+
+	assert.True(stateMachine.Current().Matches(OffState))
+	assert.Len(reached, 0)
+}
+
+func TestStoppedMachineCancelsServicesAndRejectsEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	cancelled := make(chan struct{})
+	reached := make(chan brainy.Event, 1)
+	stateMachine := newFetchingMachine(t, func(ctx context.Context, c brainy.Context, e brainy.Event) (interface{}, error) {
+		<-ctx.Done()
+		close(cancelled)
+
+		return nil, ctx.Err()
+	}, reached)
+
+	stateMachine.Stop()
+
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Fatal("service has not been cancelled")
+	}
+
+	_, err := stateMachine.Send(CancelEvent)
+	assert.ErrorIs(err, brainy.ErrMachineStopped)
+	assert.True(stateMachine.Current().Matches(FetchingState))
+}
+
+func TestInvokedServicesMustBeIdentified(t *testing.T) {
+	assert := assert.New(t)
+
+	stateMachine, err := brainy.NewMachine(brainy.StateNode{
+		Initial: FetchingState,
+
+		States: brainy.StateNodes{
+			FetchingState: &brainy.StateNode{
+				Invoke: brainy.Invokes{
+					{
+						Src: func(ctx context.Context, c brainy.Context, e brainy.Event) (interface{}, error) {
+							return nil, nil
+						},
+					},
+				},
+			},
+		},
+	})
+	assert.Nil(stateMachine)
+	assert.ErrorIs(err, brainy.ErrBlankInvokeID)
+}
@@ -0,0 +1,246 @@
+package brainy
+
+import "fmt"
+
+// A LintRule identifies a kind of mistake reported by Lint.
+type LintRule string
+
+const (
+	// LintUnreachableState reports state nodes that no transition can enter from the initial state.
+	LintUnreachableState LintRule = "unreachable-state"
+	// LintDeadEndState reports atomic state nodes that are not final and that no transition can exit.
+	LintDeadEndState LintRule = "dead-end-state"
+	// LintShadowedHandler reports events handled by a state node that are also handled by one of its ancestors,
+	// whose handler is then never used while the state node is active.
+	// Events deliberately ignored with a ForbiddenTransition are not reported.
+	LintShadowedHandler LintRule = "shadowed-handler"
+	// LintDeadTransition reports transitions that come after a transition without guard for the same event,
+	// and that can never be taken.
+	LintDeadTransition LintRule = "dead-transition"
+)
+
+// A LintIssue is a mistake found by Lint in a state node.
+type LintIssue struct {
+	Rule      LintRule `json:"rule"`
+	StateNode string   `json:"stateNode"`
+	Event     string   `json:"event,omitempty"`
+	Message   string   `json:"message"`
+}
+
+func (issue LintIssue) String() string {
+	return issue.StateNode + ": " + issue.Message + " (" + string(issue.Rule) + ")"
+}
+
+// Lint analyzes the graph of a definition and returns the issues found, in the document order
+// of the state nodes.
+//
+// Events are compared by their exact type: event descriptors such as `error.*` are not expanded.
+func Lint(graph Graph) []LintIssue {
+	linter := newGraphLinter(graph)
+
+	return linter.lint()
+}
+
+// Lint analyzes the graph of the definition. See Lint.
+func (definition *Definition) Lint() []LintIssue {
+	return Lint(definition.Graph())
+}
+
+type graphLinter struct {
+	root      *GraphStateNode
+	nodesByID map[string]*GraphStateNode
+	parents   map[*GraphStateNode]*GraphStateNode
+	reachable map[*GraphStateNode]bool
+	issues    []LintIssue
+}
+
+func newGraphLinter(graph Graph) *graphLinter {
+	linter := &graphLinter{
+		root:      &graph.Root,
+		nodesByID: make(map[string]*GraphStateNode),
+		parents:   make(map[*GraphStateNode]*GraphStateNode),
+		reachable: make(map[*GraphStateNode]bool),
+		issues:    make([]LintIssue, 0),
+	}
+
+	var indexNode func(node *GraphStateNode)
+	indexNode = func(node *GraphStateNode) {
+		linter.nodesByID[node.ID] = node
+
+		for index := range node.States {
+			childNode := &node.States[index]
+
+			linter.parents[childNode] = node
+			indexNode(childNode)
+		}
+	}
+	indexNode(linter.root)
+
+	return linter
+}
+
+func (linter *graphLinter) lint() []LintIssue {
+	linter.computeReachableNodes()
+	linter.lintNode(linter.root)
+
+	return linter.issues
+}
+
+func (linter *graphLinter) report(rule LintRule, node *GraphStateNode, event string, format string, args ...interface{}) {
+	linter.issues = append(linter.issues, LintIssue{
+		Rule:      rule,
+		StateNode: node.ID,
+		Event:     event,
+		Message:   fmt.Sprintf(format, args...),
+	})
+}
+
+// computeReachableNodes enters the root state node, and then follows the transitions of the entered
+// state nodes until no new state node is entered.
+func (linter *graphLinter) computeReachableNodes() {
+	nodesToVisit := make([]*GraphStateNode, 0)
+
+	var enter func(node *GraphStateNode)
+	enter = func(node *GraphStateNode) {
+		for ancestor := node; ancestor != nil && !linter.reachable[ancestor]; ancestor = linter.parents[ancestor] {
+			linter.reachable[ancestor] = true
+			nodesToVisit = append(nodesToVisit, ancestor)
+		}
+
+		if initialNode := node.child(node.Initial); initialNode != nil {
+			enter(initialNode)
+		}
+	}
+
+	enter(linter.root)
+
+	for len(nodesToVisit) > 0 {
+		node := nodesToVisit[0]
+		nodesToVisit = nodesToVisit[1:]
+
+		transitions := append(append(append([]GraphTransition{}, node.Transitions...), node.Choice...), node.Junction...)
+		for _, transition := range transitions {
+			if targetedNode, ok := linter.nodesByID[transition.Target]; ok {
+				enter(targetedNode)
+			}
+		}
+	}
+}
+
+func (linter *graphLinter) lintNode(node *GraphStateNode) {
+	if !linter.reachable[node] {
+		linter.report(LintUnreachableState, node, "", "state is unreachable from the initial state")
+	}
+
+	if linter.isDeadEnd(node) {
+		linter.report(LintDeadEndState, node, "", "state is not final and has no outgoing transition")
+	}
+
+	linter.lintShadowedHandlers(node)
+	linter.lintDeadTransitions(node, node.Transitions)
+	linter.lintDeadTransitions(node, node.Choice)
+	linter.lintDeadTransitions(node, node.Junction)
+
+	for index := range node.States {
+		linter.lintNode(&node.States[index])
+	}
+}
+
+// isDeadEnd returns whether the node is a non-final atomic state node that can not be exited,
+// taking the handlers of its ancestors into account.
+func (linter *graphLinter) isDeadEnd(node *GraphStateNode) bool {
+	isRootNode := node == linter.root
+	if isRootNode || node.Final || len(node.States) > 0 || len(node.Choice) > 0 || len(node.Junction) > 0 {
+		return false
+	}
+
+	forbiddenEvents := make(map[string]bool)
+
+	for ancestor := node; ancestor != nil; ancestor = linter.parents[ancestor] {
+		for _, transition := range ancestor.Transitions {
+			if forbiddenEvents[transition.Event] {
+				continue
+			}
+
+			if transition.Forbidden {
+				forbiddenEvents[transition.Event] = true
+				continue
+			}
+
+			if transition.Target != "" {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func (linter *graphLinter) lintShadowedHandlers(node *GraphStateNode) {
+	reportedEvents := make(map[string]bool)
+
+	for _, transition := range node.Transitions {
+		if transition.Forbidden || reportedEvents[transition.Event] {
+			continue
+		}
+
+		for ancestor := linter.parents[node]; ancestor != nil; ancestor = linter.parents[ancestor] {
+			if !ancestor.handles(transition.Event) {
+				continue
+			}
+
+			reportedEvents[transition.Event] = true
+			linter.report(LintShadowedHandler, node, transition.Event, "event %s shadows the handler of %s", transition.Event, ancestor.ID)
+			break
+		}
+	}
+}
+
+// lintDeadTransitions reports the transitions of an event that follow a transition without guard.
+// Choice and junction transitions do not have an event, so they are linted as the transitions of the same event.
+func (linter *graphLinter) lintDeadTransitions(node *GraphStateNode, transitions []GraphTransition) {
+	eventsWithUnguardedTransition := make(map[string]bool)
+	positionsInEvents := make(map[string]int)
+
+	for _, transition := range transitions {
+		positionInEvent := positionsInEvents[transition.Event]
+		positionsInEvents[transition.Event]++
+
+		if eventsWithUnguardedTransition[transition.Event] {
+			linter.report(LintDeadTransition, node, transition.Event, "transition %d%s follows a transition without guard and can never be taken", positionInEvent, describeEvent(transition.Event))
+			continue
+		}
+
+		if transition.Guard == "" {
+			eventsWithUnguardedTransition[transition.Event] = true
+		}
+	}
+}
+
+func describeEvent(event string) string {
+	if event == "" {
+		return ""
+	}
+
+	return " of event " + event
+}
+
+func (node *GraphStateNode) child(key string) *GraphStateNode {
+	for index := range node.States {
+		if node.States[index].Key == key {
+			return &node.States[index]
+		}
+	}
+
+	return nil
+}
+
+func (node *GraphStateNode) handles(event string) bool {
+	for _, transition := range node.Transitions {
+		if transition.Event == event {
+			return true
+		}
+	}
+
+	return false
+}
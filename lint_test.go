@@ -0,0 +1,113 @@
+package brainy_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Devessier/brainy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLint(t *testing.T) {
+	assert := assert.New(t)
+
+	const (
+		OrphanState brainy.StateType = "orphan"
+		StuckState  brainy.StateType = "stuck"
+		DoneState   brainy.StateType = "done"
+	)
+
+	isEnabled := func(c brainy.Context, e brainy.Event) bool {
+		return true
+	}
+
+	definition, err := brainy.NewDefinition(brainy.StateNode{
+		Initial: CompoundState,
+
+		States: brainy.StateNodes{
+			CompoundState: &brainy.StateNode{
+				Initial: NestedAState,
+
+				Order: []brainy.StateType{NestedAState, NestedBState},
+
+				States: brainy.StateNodes{
+					NestedAState: &brainy.StateNode{
+						On: brainy.Events{
+							ExitCompoundStateEvent: brainy.ForbiddenTransition{},
+							GoToNestedBStateEvent: brainy.Transitions{
+								{
+									Target: NestedBState,
+								},
+								{
+									Cond:   isEnabled,
+									Target: NestedAState,
+								},
+							},
+						},
+					},
+
+					NestedBState: &brainy.StateNode{
+						On: brainy.Events{
+							OnEvent: brainy.AbsoluteTarget(StuckState),
+						},
+					},
+				},
+
+				On: brainy.Events{
+					ExitCompoundStateEvent: DoneState,
+					OnEvent:                DoneState,
+				},
+			},
+
+			StuckState: &brainy.StateNode{},
+
+			OrphanState: &brainy.StateNode{
+				On: brainy.Events{
+					OnEvent: DoneState,
+				},
+			},
+
+			DoneState: &brainy.StateNode{
+				Final: true,
+			},
+		},
+
+		Order: []brainy.StateType{CompoundState, StuckState, OrphanState, DoneState},
+	})
+	assert.NoError(err)
+
+	expectedIssues := []brainy.LintIssue{
+		{
+			Rule:      brainy.LintDeadTransition,
+			StateNode: "(machine).compound.nested-a",
+			Event:     string(GoToNestedBStateEvent),
+			Message:   "transition 1 of event GO_TO_NESTED_B_STATE follows a transition without guard and can never be taken",
+		},
+		{
+			Rule:      brainy.LintShadowedHandler,
+			StateNode: "(machine).compound.nested-b",
+			Event:     string(OnEvent),
+			Message:   "event on shadows the handler of (machine).compound",
+		},
+		{
+			Rule:      brainy.LintDeadEndState,
+			StateNode: "(machine).stuck",
+			Message:   "state is not final and has no outgoing transition",
+		},
+		{
+			Rule:      brainy.LintUnreachableState,
+			StateNode: "(machine).orphan",
+			Message:   "state is unreachable from the initial state",
+		},
+	}
+	assert.Equal(expectedIssues, definition.Lint())
+
+	// The graph can be serialized and linted by another program.
+	serializedGraph, err := json.Marshal(definition.Graph())
+	assert.NoError(err)
+
+	var graph brainy.Graph
+	assert.NoError(json.Unmarshal(serializedGraph, &graph))
+	assert.Equal(definition.Graph(), graph)
+	assert.Equal(expectedIssues, brainy.Lint(graph))
+}
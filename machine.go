@@ -2,6 +2,7 @@ package brainy
 
 import (
 	"errors"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -39,8 +40,26 @@ var (
 	// but all transition guards returned false.
 	// This is usually not an issue.
 	ErrNoTransitionCouldBeRun = errors.New("no transition could be run, due to all guards having returned false")
+	// ErrMachineStopped is returned by Send when the state machine has been stopped.
+	ErrMachineStopped = errors.New("state machine is stopped")
 )
 
+// ErrNoTransitionCouldBeRunWithDetails is returned when all guards of the transitions
+// that could handle an event returned false.
+// It unwraps as a ErrNoTransitionCouldBeRun error and lists the guards that were evaluated.
+type ErrNoTransitionCouldBeRunWithDetails struct {
+	Event  Event
+	Guards []string
+}
+
+func (err *ErrNoTransitionCouldBeRunWithDetails) Error() string {
+	return ErrNoTransitionCouldBeRun.Error() + " (event: " + string(err.Event.eventType()) + ", guards: " + strings.Join(err.Guards, ", ") + ")"
+}
+
+func (err *ErrNoTransitionCouldBeRunWithDetails) Unwrap() error {
+	return ErrNoTransitionCouldBeRun
+}
+
 // ErrNoHandlerToHandleEvent is returned when an event could not be handled.
 // This is not a fatal error, but just an indication that a event to the state
 // machine could not be intercepted.
@@ -66,19 +85,53 @@ func (err *ErrNoHandlerToHandleEvent) Is(target error) bool {
 // ErrInvalidTransitionNotImplementedWithDetails is returned when a transition definition
 // was found invalid, during state machine configuration validation.
 // It unwraps as a ErrInvalidTransitionNotImplemented error and adds context about the failing transition.
+// Err holds the reason why the target could not be resolved, such as ErrUnknownTargetStateNode
+// or ErrAmbiguousTargetStateNode, and can be checked with errors.Is.
 type ErrInvalidTransitionNotImplementedWithDetails struct {
 	From   *StateNode
 	Target Targeter
+	Err    error
 }
 
 func (err *ErrInvalidTransitionNotImplementedWithDetails) Error() string {
-	return "transition not implemented (source: " + err.From.id + ", target: " + err.Target.String() + ")"
+	message := "transition not implemented (source: " + err.From.id + ", target: " + err.Target.String() + ")"
+	if err.Err != nil {
+		message += ": " + err.Err.Error()
+	}
+
+	return message
 }
 
 func (err *ErrInvalidTransitionNotImplementedWithDetails) Unwrap() error {
 	return ErrInvalidTransitionNotImplemented
 }
 
+// Is returns whether the target error is the reason why the target could not be resolved.
+func (err *ErrInvalidTransitionNotImplementedWithDetails) Is(target error) bool {
+	return err.Err != nil && errors.Is(err.Err, target)
+}
+
+// ErrInvalidStateOrder is returned when the Order of a state node lists a state that is not one of its children,
+// or lists a child several times.
+type ErrInvalidStateOrder struct {
+	InvalidState StateType
+}
+
+func (err *ErrInvalidStateOrder) Error() string {
+	return "order references an invalid or duplicated state node: " + string(err.InvalidState)
+}
+
+// Is returns whether the target error is a ErrInvalidStateOrder error
+// and if its InvalidState is the same as err's one.
+func (err *ErrInvalidStateOrder) Is(target error) bool {
+	t, ok := target.(*ErrInvalidStateOrder)
+	if !ok {
+		return false
+	}
+
+	return t.InvalidState == err.InvalidState
+}
+
 type ErrInvalidInitialState struct {
 	InvalidInitialState StateType
 }
@@ -114,6 +167,22 @@ func (err *ErrTransition) Error() string {
 	return "could not transition to: " + string(err.Event) + ": " + err.Err.Error()
 }
 
+// ErrPendingEvents is returned by Send when an error interrupted the processing of events and
+// the machine has been configured with ReturnPendingEvents policy.
+// It holds the events that were still queued when the error occured, and unwraps as the original error.
+type ErrPendingEvents struct {
+	Err    error
+	Events []Event
+}
+
+func (err *ErrPendingEvents) Unwrap() error {
+	return err.Err
+}
+
+func (err *ErrPendingEvents) Error() string {
+	return err.Err.Error() + " (" + strconv.Itoa(len(err.Events)) + " pending events)"
+}
+
 // actionType represents the different types of actions that are possible in a state machine.
 type actionType string
 
@@ -157,9 +226,9 @@ func (s StateType) transitions() []Transition {
 	}
 }
 
-func (s StateType) target() []StateType {
-	return []StateType{
-		s,
+func (s StateType) branches() [][]StateType {
+	return [][]StateType{
+		{s},
 	}
 }
 
@@ -172,6 +241,19 @@ func (s StateType) String() string {
 // blank.
 const NoneState StateType = ""
 
+// A CompoundTarget targets descendants of sibling states, branch by branch.
+// Each key is a state and its value targets states nested in it, so that
+// several branches can be targeted at once:
+//
+//  CompoundTarget{
+//      CompoundState: CompoundTarget{
+//          NestedAState: AtomicState,
+//      },
+//  }
+//
+// All branches of a CompoundTarget must be consistent: a branch can only go deeper
+// into the state node targeted by another branch. Branches diverging into distinct
+// states would require parallel state nodes, that are not supported.
 type CompoundTarget map[StateType]Targeter
 
 func (c CompoundTarget) transitions() []Transition {
@@ -182,38 +264,41 @@ func (c CompoundTarget) transitions() []Transition {
 	}
 }
 
-func (c CompoundTarget) target() []StateType {
-	states := make([]StateType, 0)
+func (c CompoundTarget) branches() [][]StateType {
+	branches := make([][]StateType, 0, len(c))
 
-	parentStateIndex := 0
-	for parentState, nodes := range c {
-		// Currently we only want to handle transitions to a single branch of states.
-		if parentStateIndex > 0 {
-			break
-		}
+	// A map does not keep the order of its keys, so branches are sorted to be deterministic.
+	parentStates := make([]StateType, 0, len(c))
+	for parentState := range c {
+		parentStates = append(parentStates, parentState)
+	}
+	sortStateTypes(parentStates)
 
-		states = append(states, parentState)
-		states = append(states, nodes.target()...)
+	for _, parentState := range parentStates {
+		for _, childBranch := range c[parentState].branches() {
+			branch := append([]StateType{parentState}, childBranch...)
 
-		parentStateIndex++
+			branches = append(branches, removeDuplicatesFromStateTypeSlice(branch))
+		}
 	}
 
-	return removeDuplicatesFromStateTypeSlice(states)
+	return branches
 }
 
 func (c CompoundTarget) String() string {
-	targets := c.target()
-	targetsAsString := make([]string, 0, len(targets))
+	branches := c.branches()
+	branchesAsString := make([]string, 0, len(branches))
 
-	for _, target := range targets {
-		targetsAsString = append(targetsAsString, target.String())
+	for _, branch := range branches {
+		branchesAsString = append(branchesAsString, joinStateTypes(branch...))
 	}
 
-	return strings.Join(targetsAsString, ".")
+	return strings.Join(branchesAsString, ", ")
 }
 
 type Targeter interface {
-	target() []StateType
+	// branches returns the paths of states targeted, one path per branch.
+	branches() [][]StateType
 	String() string
 }
 
@@ -245,6 +330,8 @@ type Action func(Context, Event) error
 // The wrapper is necessary in the current API to allow built-in actions, such as Send or Assign.
 type actionFn struct {
 	Fn Action
+	// pure reports whether the function has no side effect. See PureActionFn.
+	pure bool
 }
 
 func (a actionFn) run(c Context, e Event) error {
@@ -308,6 +395,27 @@ type Transitioner interface {
 // or if you need only one guard, using a unique Transition is sufficient.
 type Transitions []Transition
 
+// ForbiddenTransition is a Transitioner that deliberately ignores an event.
+//
+// When a state node handles an event with a ForbiddenTransition, the search for a handler stops there
+// instead of bubbling up to its ancestors: sending the event is a no-op, that neither transitions the state
+// machine nor returns an error.
+//
+//  On: brainy.Events{
+//      CancelEvent: brainy.ForbiddenTransition{},
+//  }
+type ForbiddenTransition struct{}
+
+func (ForbiddenTransition) transitions() []Transition {
+	return nil
+}
+
+func isForbiddenTransition(eventHandler Transitioner) bool {
+	_, ok := eventHandler.(ForbiddenTransition)
+
+	return ok
+}
+
 func (t Transitions) transitions() []Transition {
 	return t
 }
@@ -326,13 +434,36 @@ type Cond func(Context, Event) bool
 // the transition will be validated.
 // It is possible to have a slice of Transition and none of them returning true. No Transition will be taken.
 //
+// The Guard can be used instead of the Cond, to use a NamedGuard or to combine guards with And, Or, Not and In.
+// A Transition can not have both a Cond and a Guard.
+//
+// Transitions are internal by default: when a compound state node handles an event whose Target is one of its descendants,
+// the compound state node is not exited, only its active descendants are. When External is true, the compound state node
+// is exited and reentered, as with SCXML external transitions.
+// A transition whose Target is the state node handling the event always exits and reenters it.
+//
 // The Actions is a slice of Actions functions, that are run when the transition is taken. These functions
-// can be used to do fire-and-forget actions, or to assign values to the context of the state machine,
-// as currently there is no built-in assign action in brainy.
+// can be used to do fire-and-forget actions, or to assign values to the context of the state machine
+// with the built-in Assign action.
 type Transition struct {
-	Cond    Cond
-	Target  Targeter
-	Actions Actions
+	Cond     Cond
+	Guard    Guard
+	Target   Targeter
+	Actions  Actions
+	External bool
+}
+
+// guard returns the guard of the transition, or nil if the transition is not guarded.
+func (t Transition) guard() Guard {
+	if t.Guard != nil {
+		return t.Guard
+	}
+
+	if t.Cond != nil {
+		return t.Cond
+	}
+
+	return nil
 }
 
 func (t Transition) isTargetBlank() bool {
@@ -345,8 +476,27 @@ func (t Transition) transitions() []Transition {
 
 // Events map holds which events to listen to and their corresponding Transitions.
 // We can use as values a single Transition as well as a Transitions slice.
+//
+// Keys are event descriptors: besides exact event types, `*` matches any event and
+// a dotted prefix such as `error` or `error.*` matches the events of its family, like
+// `error.platform.fetch`. The most specific descriptor handles the event.
 type Events map[EventType]Transitioner
 
+// sortedEventTypes returns the event types of the events, sorted alphabetically, so that
+// events can be iterated over in a deterministic order.
+func (e Events) sortedEventTypes() []EventType {
+	eventTypes := make([]EventType, 0, len(e))
+	for eventType := range e {
+		eventTypes = append(eventTypes, eventType)
+	}
+
+	sort.Slice(eventTypes, func(i, j int) bool {
+		return eventTypes[i] < eventTypes[j]
+	})
+
+	return eventTypes
+}
+
 func joinStatesIDs(statesIDs ...string) string {
 	concatenatedID := ""
 
@@ -375,35 +525,75 @@ func joinStateTypes(statesIDs ...StateType) string {
 // A StateNode is a node of the state machine.
 // It has a map of Events to listen to, OnEntry actions to run when the state is entered and
 // OnExit actions to run when the state is exited.
+// Services listed in Invoke are started when the state is entered and cancelled when it is exited.
 //
 // All these fields are optional.
-// When no events are specified, the state node is of *final* type, which means once reached, the state
-// machine can not be transitioned anymore.
+// A state node with Final set to true is of *final* type: once a final child of the root state node is reached,
+// the state machine is done.
+// Tags are labels attached to the state node, that are reported by the states in which the state node is active.
+//
+// A state node with Junction transitions is a *junction* pseudo-state, a static conditional branch: when it is
+// targeted, the first of its transitions whose guard passes is taken within the same microstep. Its guards are
+// evaluated when the transition targeting it is selected, against the context from before the actions of
+// this transition.
+//
+// A state node with Choice transitions is a *choice* pseudo-state, a dynamic conditional branch: the first of
+// its transitions whose guard passes is taken once the actions of the transition targeting it have run, so that
+// its guards see the context updated by these actions, such as Assign actions.
+//
+// Choice and junction state nodes are never reported as entered or exited, and the current state node is never
+// one of them: when a choice state node can not pick a transition, or when an action run after it fails,
+// the state machine stays in the state node it was in before the event.
+// Their last transition must not have a guard, and they can not have any other field.
 type StateNode struct {
-	id string
+	id  string
+	key StateType
+
+	// ID uniquely identifies the state node in the state machine.
+	// Transitions can target the state node from anywhere with `#id`; see TargetID.
+	ID string
 
 	Context Context
 
 	Initial StateType
 
 	States StateNodes
+	// Order lists the names of the children state nodes in document order, as Go maps
+	// do not keep the order in which keys were declared.
+	// Children that are not listed come after the listed ones, sorted alphabetically.
+	Order []StateType
 
 	OnEntry Actions
 	OnExit  Actions
 
+	Invoke Invokes
+
 	On Events
 
-	machine         *Machine
-	parentStateNode *StateNode
-	machineID       StateType
+	Choice   Transitions
+	Junction Transitions
+
+	Final bool
+	Tags  []string
+
+	definition              *Definition
+	parentStateNode         *StateNode
+	childrenInDocumentOrder []*StateNode
+	machineID               StateType
 }
 
 func (s *StateNode) Value() string {
 	return s.id
 }
 
+// name returns the key of the state node in the StateNodes of its parent.
+func (s *StateNode) name() StateType {
+	return s.key
+}
+
 // Matches returns whether or not the state node is a descendant of the parent state value.
 // It takes the parent state value as a variadic list of StateType.
+// Selectors are compared segment by segment: a selector never matches a state whose name only begins with it.
 //
 // Given that the id of the StateNode is `compound.atomic`:
 //  state.Matches(CompoundState)
@@ -414,6 +604,9 @@ func (s *StateNode) Value() string {
 //
 //  state.Matches(UnknownState)
 //  // => false
+//
+//  state.Matches("compo")
+//  // => false
 func (s *StateNode) Matches(stateSelectors ...StateType) bool {
 	selectorsWithMachineID := make([]StateType, 0, len(stateSelectors)+1)
 	selectorsWithMachineID = append(selectorsWithMachineID, s.machineID)
@@ -421,18 +614,56 @@ func (s *StateNode) Matches(stateSelectors ...StateType) bool {
 
 	rebuiltStateID := joinStateTypes(selectorsWithMachineID...)
 
-	doesMatch := strings.HasPrefix(s.id, rebuiltStateID)
-	return doesMatch
+	return isSameOrDescendantID(s.id, rebuiltStateID)
 }
 
-func (s *StateNode) setChildrenStateNodesIDs(parentStateNodeID string, machineID StateType, machine *Machine) {
-	for childStateNodeName, childStateNode := range s.States {
+// isSameOrDescendantID returns whether id is ancestorID or the id of one of its descendants.
+func isSameOrDescendantID(id string, ancestorID string) bool {
+	return id == ancestorID || strings.HasPrefix(id, ancestorID+".")
+}
+
+// documentOrder returns the names of the children state nodes in document order:
+// the names listed in Order come first, followed by the other children sorted alphabetically.
+// Names of Order that do not reference a child are ignored; they are reported by validate.
+func (s *StateNode) documentOrder() []StateType {
+	orderedStateTypes := make([]StateType, 0, len(s.States))
+	encounteredStateTypes := make(map[StateType]bool, len(s.States))
+
+	for _, stateType := range s.Order {
+		if _, ok := s.States[stateType]; !ok || encounteredStateTypes[stateType] {
+			continue
+		}
+
+		encounteredStateTypes[stateType] = true
+		orderedStateTypes = append(orderedStateTypes, stateType)
+	}
+
+	remainingStateTypes := make([]StateType, 0, len(s.States)-len(orderedStateTypes))
+	for stateType := range s.States {
+		if !encounteredStateTypes[stateType] {
+			remainingStateTypes = append(remainingStateTypes, stateType)
+		}
+	}
+	sortStateTypes(remainingStateTypes)
+
+	return append(orderedStateTypes, remainingStateTypes...)
+}
+
+func (s *StateNode) setChildrenStateNodesIDs(parentStateNodeID string, machineID StateType, definition *Definition) {
+	s.childrenInDocumentOrder = make([]*StateNode, 0, len(s.States))
+
+	for _, childStateNodeName := range s.documentOrder() {
+		childStateNode := s.States[childStateNodeName]
+		s.childrenInDocumentOrder = append(s.childrenInDocumentOrder, childStateNode)
+
 		childStateNode.id = joinStatesIDs(parentStateNodeID, childStateNodeName.String())
+		childStateNode.key = childStateNodeName
 		childStateNode.machineID = machineID
-		childStateNode.machine = machine
+		childStateNode.parentStateNode = s
+		childStateNode.definition = definition
 
 		if childStateNode.isCompound() {
-			childStateNode.setChildrenStateNodesIDs(childStateNode.id, machineID, machine)
+			childStateNode.setChildrenStateNodesIDs(childStateNode.id, machineID, definition)
 		}
 	}
 }
@@ -454,21 +685,15 @@ func (s *StateNode) resolveMostNestedInitialStateNode() *StateNode {
 	return initialStateNode.resolveMostNestedInitialStateNode()
 }
 
-func (s *StateNode) getTarget(target Targeter, expectedIDBeginning string) (*StateNode, bool) {
-	for _, childStateNode := range s.States {
-		if stateNodeIDBeginsWithTargetID := strings.HasPrefix(childStateNode.id, expectedIDBeginning); stateNodeIDBeginsWithTargetID {
-			return childStateNode.resolveMostNestedInitialStateNode(), true
-		}
-
-		if matchingChildStateNode, ok := childStateNode.getTarget(target, expectedIDBeginning); ok {
-			return matchingChildStateNode, true
-		}
+// executeActioner runs the actioner. The owner is the state node that is entered when the actioner is an entry action,
+// and nil otherwise.
+func (machine *Machine) executeActioner(actioner Actioner, owner *StateNode, event Event) error {
+	if machine.suppressSideEffects && hasSideEffects(actioner) {
+		return machine.runSuppressedAction(actioner, machine.context, event)
 	}
 
-	return nil, false
-}
+	context := machine.context
 
-func executeActioner(actioner Actioner, machine *Machine, context Context, event Event) error {
 	switch action := actioner.(type) {
 	case actionFn:
 		if err := action.run(context, event); err != nil {
@@ -476,6 +701,32 @@ func executeActioner(actioner Actioner, machine *Machine, context Context, event
 		}
 	case sendActionEvent:
 		machine.externalEvents.Add(action.SourceEvent)
+	case assignAction:
+		machine.context = action.Fn(context, event)
+	case spawnAction:
+		return machine.spawn(action, owner, context, event)
+	case sendToAction:
+		child := action.To(context, event)
+		if child == nil {
+			return ErrNoChildMachine
+		}
+
+		machine.addToOutbox(child, action.SourceEvent)
+	case forwardToAction:
+		child := action.To(context, event)
+		if child == nil {
+			return ErrNoChildMachine
+		}
+
+		machine.addToOutbox(child, event)
+	case sendParentAction:
+		if machine.parent == nil {
+			return ErrNoParentMachine
+		}
+
+		machine.addToOutbox(machine.parent, action.SourceEvent)
+	case sendAfterAction:
+		machine.scheduleEvent(action, owner)
 	default:
 		return errors.New("unexpected actioner")
 	}
@@ -553,145 +804,120 @@ func findLeastCommonCompoundAncestor(stateNodes []*StateNode) *StateNode {
 	return nil
 }
 
-func (s *StateNode) executeOnEntryActions(c Context, e Event, leastCommonCompoundAncestor *StateNode) error {
-	actionsToCall := make([]Actioner, 0)
-
-	stateNodeToEntry := s
-
-	for stateNodeToEntry != leastCommonCompoundAncestor {
-		if onEntryActions := stateNodeToEntry.OnEntry; onEntryActions != nil {
-			actionsToCall = append(actionsToCall, onEntryActions...)
-		}
-
-		stateNodeToEntry = stateNodeToEntry.parentStateNode
-	}
-
-	countOfActions := len(actionsToCall)
-	actionsToCallInReverseOrder := make([]Actioner, countOfActions)
+// A StateNodes holds all state nodes of a machine.
+type StateNodes map[StateType]*StateNode
 
-	for index, action := range actionsToCall {
-		actionsToCallInReverseOrder[countOfActions-index-1] = action
-	}
+type MachineOption func(*Machine)
 
-	for index, actioner := range actionsToCallInReverseOrder {
-		if err := executeActioner(actioner, s.machine, c, e); err != nil {
-			return &ErrAction{
-				Type: onEntryActionType,
-				ID:   index,
-				Err:  err,
-			}
-		}
+// WithDisableLocking disables mutex usage.
+// It is NOT RECOMMENDED in most cases.
+func WithDisableLocking() MachineOption {
+	return func(machine *Machine) {
+		machine.disableLocking = true
 	}
-
-	return nil
 }
 
-func (s *StateNode) executeOnExitActions(c Context, e Event, leastCommonCompoundAncestor *StateNode) error {
-	stateNodeToExit := s
+// PendingEventsPolicy describes what happens to the events that are still queued
+// when an error interrupts the processing of events in Send.
+// Events can be queued while an event is being processed, for example by Send actions.
+type PendingEventsPolicy int
 
-	for stateNodeToExit != leastCommonCompoundAncestor {
-		if onExitActions := stateNodeToExit.OnExit; onExitActions != nil {
-			for index, actioner := range onExitActions {
-				if err := executeActioner(actioner, s.machine, c, e); err != nil {
-					return &ErrAction{
-						Type: onExitActionType,
-						ID:   index,
-						Err:  err,
-					}
-				}
-			}
-		}
+const (
+	// KeepPendingEvents keeps the pending events in the queue. They will be processed
+	// before the event given to the next call to Send.
+	// This is the default policy.
+	KeepPendingEvents PendingEventsPolicy = iota
+	// DropPendingEvents discards the pending events.
+	DropPendingEvents
+	// ReturnPendingEvents removes the pending events from the queue and returns them
+	// within an ErrPendingEvents error.
+	ReturnPendingEvents
+)
 
-		stateNodeToExit = stateNodeToExit.parentStateNode
+// WithContextFactory sets the function returning the initial context of the state machine, which replaces
+// the context of the root state node of the definition. State machines of a definition share the context of its
+// root state node: a factory gives each of them its own context when it is updated in place, such as a pointer.
+func WithContextFactory(factory func() Context) MachineOption {
+	return func(machine *Machine) {
+		machine.context = factory()
 	}
-
-	return nil
 }
 
-func (s *StateNode) validate(m *Machine) error {
-	if s.isAtomic() {
-		return nil
+// WithPendingEventsPolicy sets the policy applied to pending events when an error
+// interrupts the processing of events.
+func WithPendingEventsPolicy(policy PendingEventsPolicy) MachineOption {
+	return func(machine *Machine) {
+		machine.pendingEventsPolicy = policy
 	}
+}
 
-	if s.Initial == NoneState {
-		return ErrBlankInitialStateForCompoundState
+// NewMachine takes a StateNode configuration and returns a Machine if one could be created from the given configuration.
+// The configuration is validated so that impossible transitions are not possible at runtime.
+// If the state machine could not be created, the validation error is returned.
+func NewMachine(config StateNode, options ...MachineOption) (*Machine, error) {
+	machine, err := newMachine(config, options...)
+	if err != nil {
+		return nil, err
 	}
 
-	if _, ok := s.States[s.Initial]; !ok {
-		return &ErrInvalidInitialState{
-			InvalidInitialState: s.Initial,
-		}
-	}
+	deliverMessages(machine.lockAndTakeOutbox())
 
-	for _, stateNode := range s.States {
-		// Set the parentStateNode of each state node
-		stateNode.parentStateNode = s
+	return machine, nil
+}
 
-		// Recursively validate children states
-		if stateNode.isCompound() {
-			if err := stateNode.validate(m); err != nil {
-				return err
-			}
-		}
+// newMachine creates and initializes a Machine, without delivering the messages
+// sent to other state machines during the initial transition.
+func newMachine(config StateNode, options ...MachineOption) (*Machine, error) {
+	definition, err := NewDefinition(config)
+	if err != nil {
+		return nil, err
+	}
 
-		handlers := stateNode.On
-		if handlers == nil {
-			continue
-		}
+	return newMachineFromDefinition(definition, options...)
+}
 
-		for _, events := range handlers {
-			transitions := events.transitions()
-
-			for _, transition := range transitions {
-				target := transition.Target
-				if transition.isTargetBlank() {
-					continue
-				}
-
-				_, err := s.machine.resolveStateNodeToEnter(stateNode, transition)
-				if err != nil {
-					return &ErrInvalidTransitionNotImplementedWithDetails{
-						From:   stateNode,
-						Target: target,
-					}
-				}
-			}
-		}
+// NewMachineFromDefinition returns a Machine that runs the given definition.
+// A definition can be shared by several state machines, as long as it is not modified.
+// The context of the state machine is initialized with the context of the root state node of the definition,
+// unless WithContextFactory is given.
+func NewMachineFromDefinition(definition *Definition, options ...MachineOption) (*Machine, error) {
+	machine, err := newMachineFromDefinition(definition, options...)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
-}
+	deliverMessages(machine.lockAndTakeOutbox())
 
-// A StateNodes holds all state nodes of a machine.
-type StateNodes map[StateType]*StateNode
+	return machine, nil
+}
 
-type MachineOption func(*Machine)
+func newMachineFromDefinition(definition *Definition, options ...MachineOption) (*Machine, error) {
+	machine := newUninitializedMachine(definition, options...)
 
-// WithDisableLocking disables mutex usage.
-// It is NOT RECOMMENDED in most cases.
-func WithDisableLocking() MachineOption {
-	return func(machine *Machine) {
-		machine.disableLocking = true
+	if err := machine.init(); err != nil {
+		return nil, err
 	}
+
+	return machine, nil
 }
 
-// NewMachine takes a StateNode configuration and returns a Machine if one could be created from the given configuration.
-// The configuration is validated so that impossible transitions are not possible at runtime.
-// If the state machine could not be created, the validation error is returned.
-func NewMachine(config StateNode, options ...MachineOption) (*Machine, error) {
+// newUninitializedMachine returns a Machine that has not entered any state node yet.
+func newUninitializedMachine(definition *Definition, options ...MachineOption) *Machine {
 	machine := &Machine{
-		StateNode:      &config,
+		StateNode:      definition.root,
+		definition:     definition,
+		context:        definition.root.Context,
 		externalEvents: newEventsQueue(),
+		invocations:    make(map[*StateNode][]*invocation),
+		clock:          realClock{},
+		delayedEvents:  make(map[*StateNode][]*delayedEvent),
+		children:       make(map[*Machine]*StateNode),
 	}
-	if err := machine.init(); err != nil {
-		return nil, err
-	}
-
 	for _, option := range options {
 		option(machine)
 	}
 
-	return machine, nil
+	return machine
 }
 
 // A Machine is a simple finite state machine.
@@ -703,54 +929,84 @@ type Machine struct {
 
 	StateNode *StateNode
 
-	externalEvents *eventsQueue
+	definition *Definition
+	context    Context
+
+	externalEvents      *eventsQueue
+	pendingEventsPolicy PendingEventsPolicy
+
+	previous      *StateNode
+	current       *StateNode
+	state         State
+	record        *macrostepRecord
+	coverage      *Coverage
+	eventRecorder *EventRecorder
+	history       *history
+	stream        *persistedStream
 
-	previous *StateNode
-	current  *StateNode
+	invocations   map[*StateNode][]*invocation
+	clock         Clock
+	delayedEvents map[*StateNode][]*delayedEvent
+	stopped       bool
 
-	disableLocking bool
-	lock           sync.Mutex
+	parent   *Machine
+	children map[*Machine]*StateNode
+	outbox   []message
+
+	suppressSideEffects bool
+	sideEffectStub      Action
+	disableLocking      bool
+	lock                sync.Mutex
 }
 
-func (machine *Machine) setStateNodesIDs() {
-	var rootID StateType = StateType(machine.ID)
-	if rootID == "" {
-		rootID = "(machine)"
+// Init initializes the machine by entering its initial state nodes.
+func (machine *Machine) init() error {
+	// Services invoked by the initial state nodes can send events
+	// before the initialization is done.
+	if !machine.disableLocking {
+		machine.lock.Lock()
+		defer machine.lock.Unlock()
 	}
 
-	machine.StateNode.id = string(rootID)
-	machine.StateNode.machineID = rootID
-	machine.StateNode.machine = machine
+	machine.record = newMacrostepRecord()
 
-	machine.StateNode.setChildrenStateNodesIDs(string(rootID), rootID, machine)
-}
+	step := machine.definition.initialMicrostep()
+	if err := machine.executeMicrostep(step, InitialTransitionEventType); err != nil {
+		return err
+	}
+	machine.coverage.recordMicrostep(step)
 
-// Validate ensures all transitions targets are valid states.
-func (machine *Machine) validate() error {
-	machine.setStateNodesIDs()
+	machine.current = step.target
+	machine.state = newState(nil, machine.current, machine.context, InitialTransitionEventType, machine.record)
+	machine.history.recordStep(machine.state)
 
-	err := machine.StateNode.validate(machine)
+	return nil
+}
 
-	return err
+// Definition returns the definition the state machine runs.
+func (machine *Machine) Definition() *Definition {
+	return machine.definition
 }
 
-// Init initializes the machine and validates transitions target state.
-//
-// The LCCA state node passed to executeOnEntryActions is nil as we want the OnEntry actions
-// of the root state node:
-// During the initial transition, the least common compound ancestor is the parent of the root state,
-// that is, in our implementation, nil, as it does not have any parent.
-func (machine *Machine) init() error {
-	if err := machine.validate(); err != nil {
-		return err
+// Context returns the current context of the state machine.
+// The context is replaced by Assign actions.
+func (machine *Machine) Context() Context {
+	if !machine.disableLocking {
+		machine.lock.Lock()
+		defer machine.lock.Unlock()
 	}
 
-	machine.current = machine.StateNode.resolveMostNestedInitialStateNode()
-	if err := machine.current.executeOnEntryActions(machine.StateNode.Context, InitialTransitionEventType, nil); err != nil {
-		return err
+	return machine.context
+}
+
+// State returns the state reached after the last event sent to the state machine was processed.
+func (machine *Machine) State() State {
+	if !machine.disableLocking {
+		machine.lock.Lock()
+		defer machine.lock.Unlock()
 	}
 
-	return nil
+	return machine.state
 }
 
 // Previous returns previous state.
@@ -778,152 +1034,240 @@ func (machine *Machine) UnsafeCurrent() *StateNode {
 	return machine.current
 }
 
-func (machine *Machine) selectTransition(transitions []Transition, event Event) (Transition, bool) {
-	for _, transition := range transitions {
-		shouldCommitTransition := true
-		if cond := transition.Cond; cond != nil {
-			shouldCommitTransition = cond(machine.StateNode.Context, event)
+func (machine *Machine) handleExternalEvent(event Event) error {
+	step, err := planMicrostep(machine.current, machine.context, event)
+	if err != nil {
+		// Guards that were evaluated are covered even if no transition could be taken.
+		machine.coverage.recordMicrostep(microstep{
+			evaluatedGuards: step.evaluatedGuards,
+		})
+
+		return err
+	}
+
+	previous := machine.current
+	encounteredChoices := make(map[*StateNode]bool)
+
+	for {
+		if err := machine.executeMicrostep(step, event); err != nil {
+			return err
 		}
+		machine.coverage.recordMicrostep(step)
 
-		if shouldCommitTransition {
-			return transition, true
+		// Choice state nodes never become the current state node: when a microstep leaving one of them
+		// can not be run, the state machine stays in the state node it was in before the event.
+		if !step.target.isChoice() {
+			machine.previous = previous
+			machine.current = step.target
+
+			return nil
 		}
-	}
 
-	return Transition{}, false
+		// The guards of a choice state node are evaluated once the actions leading to it have run.
+		if step, err = planChoiceMicrostep(step.target, machine.context, event, encounteredChoices); err != nil {
+			machine.coverage.recordMicrostep(microstep{
+				evaluatedGuards: step.evaluatedGuards,
+			})
+
+			return err
+		}
+	}
 }
 
-func (machine *Machine) resolveStateNodeToEnter(stateNodeWithHandler *StateNode, transitionToExecute Transition) (*StateNode, error) {
-	target := transitionToExecute.Target
-	if transitionToExecute.isTargetBlank() {
-		return machine.current, nil
+// executeMicrostep exits the state nodes, runs the actions of the transition and enters the state nodes
+// described by the microstep.
+// Invoked services and children state machines of an exited state node are stopped once its exit actions have run.
+// Services of entered state nodes are started once all entry actions have run.
+func (machine *Machine) executeMicrostep(step microstep, event Event) error {
+	for _, stateNode := range step.exited {
+		if err := machine.executeActions(stateNode.exitActions(), event); err != nil {
+			return err
+		}
+
+		machine.record.exited = append(machine.record.exited, stateNode)
+
+		machine.cancelInvocations(stateNode)
+		machine.cancelDelayedEvents(stateNode)
+		machine.stopChildren(stateNode)
 	}
 
-	// The state node from which we will resolve the target is either
-	// the root state node of the state machine or the parent state node
-	// of the one that handled the event.
-	var stateNodeResolvingPoint *StateNode
-	if isParentRootStateNode := stateNodeWithHandler.parentStateNode == nil; isParentRootStateNode {
-		stateNodeResolvingPoint = stateNodeWithHandler
-	} else {
-		stateNodeResolvingPoint = stateNodeWithHandler.parentStateNode
+	if err := machine.executeActions(step.transitionActions(), event); err != nil {
+		return err
 	}
 
-	targetID := target.String()
-	expectedIDBeginning := joinStatesIDs(stateNodeResolvingPoint.id, targetID)
+	if err := machine.executeActions(entryActions(step.entered), event); err != nil {
+		return err
+	}
 
-	resolvedTargetStateNode, ok := stateNodeResolvingPoint.getTarget(target, expectedIDBeginning)
-	if !ok {
-		return nil, errors.New("could not resolve target")
+	for _, stateNode := range step.entered {
+		machine.startInvocations(stateNode, machine.context, event)
 	}
 
-	return resolvedTargetStateNode, nil
-}
+	machine.record.entered = append(machine.record.entered, step.entered...)
 
-func (machine *Machine) executeMicrotask(stateNodeToEnter *StateNode, transitionToExecute Transition, event Event) error {
-	leastCommonCompoundAncestor := findLeastCommonCompoundAncestor([]*StateNode{machine.current, stateNodeToEnter})
+	return nil
+}
 
-	if !transitionToExecute.isTargetBlank() {
-		if err := machine.current.executeOnExitActions(machine.StateNode.Context, event, leastCommonCompoundAncestor); err != nil {
-			return err
+func (machine *Machine) executeActions(actions []plannedAction, event Event) error {
+	for _, action := range actions {
+		if err := machine.executeActioner(action.actioner, action.owner, event); err != nil {
+			return &ErrAction{
+				Type: action.actionType,
+				ID:   action.index,
+				Err:  err,
+			}
 		}
+
+		machine.record.actions = append(machine.record.actions, action.actioner)
 	}
 
-	if actions := transitionToExecute.Actions; actions != nil {
-		for index, actioner := range actions {
-			if err := executeActioner(actioner, machine, machine.StateNode.Context, event); err != nil {
-				return &ErrAction{
-					Type: transitionActionActionType,
-					ID:   index,
-					Err:  err,
-				}
-			}
-		}
+	return nil
+}
+
+// Send an event to the state machine.
+// Returns the new state and an error if one occured, or nil.
+// When an error occurs, the returned state describes what happened before the error.
+//
+// Events sent to other state machines by SendTo, ForwardTo and SendParent actions are delivered
+// once the state machine has processed the event and has been unlocked.
+func (machine *Machine) Send(event Event) (State, error) {
+	state, messages, err := machine.lockAndSend(event)
+
+	deliverMessages(messages)
+
+	return state, err
+}
+
+func (machine *Machine) lockAndSend(event Event) (State, []message, error) {
+	if !machine.disableLocking {
+		machine.lock.Lock()
+		defer machine.lock.Unlock()
 	}
 
-	if !transitionToExecute.isTargetBlank() {
-		if err := stateNodeToEnter.executeOnEntryActions(machine.StateNode.Context, event, leastCommonCompoundAncestor); err != nil {
-			return err
-		}
+	if machine.stopped {
+		return newState(machine.current, machine.current, machine.context, event, newMacrostepRecord()), nil, ErrMachineStopped
 	}
 
-	return nil
+	state, err := machine.send(event)
+
+	return state, machine.takeOutbox(), err
 }
 
-func (machine *Machine) resolveStateNodeWithHandler(eventType EventType) (*StateNode, Transitioner) {
-	stateNode := machine.current
+// send adds the event to the queue and processes all queued events.
+// The caller is responsible for locking the state machine.
+func (machine *Machine) send(event Event) (State, error) {
+	machine.externalEvents.Add(event)
 
-	for stateNode != nil {
-		handlers := stateNode.On
-		if handlers == nil {
-			stateNode = stateNode.parentStateNode
-			continue
+	stateNodeBeforeEvent := machine.current
+	machine.record = newMacrostepRecord()
+
+	var err error
+	for {
+		externalEvent, ok := machine.externalEvents.Poll()
+		if !ok {
+			break
 		}
 
-		eventHandler := handlers[eventType]
-		if eventHandler == nil {
-			stateNode = stateNode.parentStateNode
-			continue
+		if err = machine.handleExternalEvent(externalEvent); err != nil {
+			err = machine.applyPendingEventsPolicy(err)
+			break
+		}
+	}
+
+	machine.state = newState(stateNodeBeforeEvent, machine.current, machine.context, event, machine.record)
+	machine.eventRecorder.record(machine.clock.Now(), event, machine.state, err)
+	if persistenceErr := machine.stream.persist(machine.clock.Now(), event, machine.state, err); persistenceErr != nil {
+		// The stream has been extended by another state machine: this one is stale, and must not
+		// move further away from the persisted state.
+		if errors.Is(persistenceErr, ErrVersionConflict) {
+			machine.stop()
 		}
 
-		return stateNode, eventHandler
+		if err == nil {
+			err = persistenceErr
+		}
 	}
+	machine.history.recordStep(machine.state)
 
-	return nil, nil
+	return machine.state, err
 }
 
-func (machine *Machine) handleExternalEvent(event Event) error {
-	eventType := event.eventType()
-	stateNodeWithHandler, eventHandler := machine.resolveStateNodeWithHandler(eventType)
-	if stateNodeWithHandler == nil {
-		return &ErrNoHandlerToHandleEvent{
-			Event: event,
+func (machine *Machine) applyPendingEventsPolicy(err error) error {
+	if machine.externalEvents.Len() == 0 {
+		return err
+	}
+
+	switch machine.pendingEventsPolicy {
+	case DropPendingEvents:
+		machine.externalEvents.Clear()
+	case ReturnPendingEvents:
+		return &ErrPendingEvents{
+			Err:    err,
+			Events: machine.externalEvents.Clear(),
 		}
 	}
 
-	transitions := eventHandler.transitions()
-	transitionToExecute, ok := machine.selectTransition(transitions, event)
-	if !ok {
-		return ErrNoTransitionCouldBeRun
+	return err
+}
+
+// Stop stops the state machine: all running invoked services are cancelled, all children
+// state machines are stopped and the state machine will not accept events anymore.
+// Exit actions of active state nodes are not run.
+func (machine *Machine) Stop() {
+	if !machine.disableLocking {
+		machine.lock.Lock()
+		defer machine.lock.Unlock()
 	}
 
-	stateNodeToEnter, err := machine.resolveStateNodeToEnter(stateNodeWithHandler, transitionToExecute)
-	if err != nil {
-		return err
+	machine.stop()
+}
+
+// stop stops the state machine.
+// The caller is responsible for locking the state machine.
+func (machine *Machine) stop() {
+	if machine.stopped {
+		return
 	}
+	machine.stopped = true
 
-	if err := machine.executeMicrotask(stateNodeToEnter, transitionToExecute, event); err != nil {
-		return err
+	for stateNode := range machine.invocations {
+		machine.cancelInvocations(stateNode)
 	}
 
-	machine.previous = machine.current
-	machine.current = stateNodeToEnter
+	for owner := range machine.delayedEvents {
+		machine.cancelDelayedEvents(owner)
+	}
 
-	return nil
+	machine.stopAllChildren()
+	machine.outbox = nil
 }
 
-// Send an event to the state machine.
-// Returns the new state and an error if one occured, or nil.
-func (machine *Machine) Send(event Event) (*StateNode, error) {
+// PendingEvents returns the events that are waiting to be processed by the state machine,
+// in the order they will be processed.
+func (machine *Machine) PendingEvents() []Event {
 	if !machine.disableLocking {
 		machine.lock.Lock()
 		defer machine.lock.Unlock()
 	}
 
-	machine.externalEvents.Add(event)
-
-	for {
-		externalEvent, ok := machine.externalEvents.Poll()
-		if !ok {
-			break
-		}
+	return machine.externalEvents.Events()
+}
 
-		if err := machine.handleExternalEvent(externalEvent); err != nil {
-			return machine.current, err
-		}
+// ClearPendingEvents removes the events that are waiting to be processed by the state machine
+// and returns them.
+func (machine *Machine) ClearPendingEvents() []Event {
+	if !machine.disableLocking {
+		machine.lock.Lock()
+		defer machine.lock.Unlock()
 	}
 
-	return machine.current, nil
+	return machine.externalEvents.Clear()
+}
+
+func sortStateTypes(s []StateType) {
+	sort.Slice(s, func(i, j int) bool {
+		return s[i] < s[j]
+	})
 }
 
 func removeDuplicatesFromStateTypeSlice(s []StateType) []StateType {
@@ -1064,3 +1064,288 @@ func TestTransitionFromRootStateNode(t *testing.T) {
 	assert.True(nextState.Matches(CompoundState, NestedBState))
 	assert.True(compoundStateMachine.Current().Matches(CompoundState, NestedBState))
 }
+
+func TestPendingEventsPolicies(t *testing.T) {
+	const (
+		FailEvent    brainy.EventType = "FAIL"
+		PendingEvent brainy.EventType = "PENDING"
+	)
+
+	actionErr := errors.New("action failed")
+
+	newStateMachine := func(options ...brainy.MachineOption) *brainy.Machine {
+		stateMachine, err := brainy.NewMachine(brainy.StateNode{
+			Initial: OffState,
+
+			States: brainy.StateNodes{
+				OffState: &brainy.StateNode{
+					On: brainy.Events{
+						FailEvent: brainy.Transition{
+							Actions: brainy.Actions{
+								brainy.Send(PendingEvent),
+								brainy.ActionFn(func(c brainy.Context, e brainy.Event) error {
+									return actionErr
+								}),
+							},
+						},
+						PendingEvent: OnState,
+						OnEvent:      OnState,
+					},
+				},
+
+				OnState: &brainy.StateNode{
+					On: brainy.Events{
+						OffEvent: OffState,
+					},
+				},
+			},
+		}, options...)
+		assert.NoError(t, err)
+
+		return stateMachine
+	}
+
+	t.Run("keeps pending events by default", func(t *testing.T) {
+		assert := assert.New(t)
+
+		stateMachine := newStateMachine()
+
+		_, err := stateMachine.Send(FailEvent)
+		assert.ErrorIs(err, actionErr)
+		assert.Equal([]brainy.Event{PendingEvent}, stateMachine.PendingEvents())
+		assert.True(stateMachine.Current().Matches(OffState))
+
+		// The pending event is processed before the one that is sent.
+		_, err = stateMachine.Send(OnEvent)
+		assert.ErrorIs(err, &brainy.ErrNoHandlerToHandleEvent{
+			Event: OnEvent,
+		})
+		assert.True(stateMachine.Current().Matches(OnState))
+	})
+
+	t.Run("drops pending events", func(t *testing.T) {
+		assert := assert.New(t)
+
+		stateMachine := newStateMachine(brainy.WithPendingEventsPolicy(brainy.DropPendingEvents))
+
+		_, err := stateMachine.Send(FailEvent)
+		assert.ErrorIs(err, actionErr)
+		assert.Empty(stateMachine.PendingEvents())
+	})
+
+	t.Run("returns pending events", func(t *testing.T) {
+		assert := assert.New(t)
+
+		stateMachine := newStateMachine(brainy.WithPendingEventsPolicy(brainy.ReturnPendingEvents))
+
+		_, err := stateMachine.Send(FailEvent)
+		assert.ErrorIs(err, actionErr)
+
+		var pendingEventsErr *brainy.ErrPendingEvents
+		assert.ErrorAs(err, &pendingEventsErr)
+		assert.Equal([]brainy.Event{PendingEvent}, pendingEventsErr.Events)
+		assert.Empty(stateMachine.PendingEvents())
+	})
+
+	t.Run("clears pending events", func(t *testing.T) {
+		assert := assert.New(t)
+
+		stateMachine := newStateMachine()
+
+		_, err := stateMachine.Send(FailEvent)
+		assert.ErrorIs(err, actionErr)
+		assert.Equal([]brainy.Event{PendingEvent}, stateMachine.ClearPendingEvents())
+		assert.Empty(stateMachine.PendingEvents())
+
+		_, err = stateMachine.Send(OnEvent)
+		assert.NoError(err)
+		assert.True(stateMachine.Current().Matches(OnState))
+	})
+}
+
+func TestValidationFollowsDocumentOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	const (
+		FirstState  brainy.StateType = "first"
+		SecondState brainy.StateType = "second"
+	)
+
+	newConfig := func(order ...brainy.StateType) brainy.StateNode {
+		return brainy.StateNode{
+			Initial: FirstState,
+
+			Order: order,
+
+			States: brainy.StateNodes{
+				FirstState: &brainy.StateNode{
+					On: brainy.Events{
+						OnEvent: brainy.StateType("unknown-from-first"),
+					},
+				},
+
+				SecondState: &brainy.StateNode{
+					On: brainy.Events{
+						OnEvent: brainy.StateType("unknown-from-second"),
+					},
+				},
+			},
+		}
+	}
+
+	for i := 0; i < 10; i++ {
+		_, err := brainy.NewMachine(newConfig(SecondState, FirstState))
+
+		var detailedErr *brainy.ErrInvalidTransitionNotImplementedWithDetails
+		assert.ErrorAs(err, &detailedErr)
+		assert.Equal("unknown-from-second", detailedErr.Target.String())
+
+		// Children that are not listed are sorted alphabetically.
+		_, err = brainy.NewMachine(newConfig())
+
+		assert.ErrorAs(err, &detailedErr)
+		assert.Equal("unknown-from-first", detailedErr.Target.String())
+	}
+
+	_, err := brainy.NewMachine(newConfig(FirstState, FirstState))
+	assert.ErrorIs(err, &brainy.ErrInvalidStateOrder{
+		InvalidState: FirstState,
+	})
+}
+
+func TestInternalAndExternalTransitions(t *testing.T) {
+	const (
+		InternalEvent brainy.EventType = "INTERNAL"
+		ExternalEvent brainy.EventType = "EXTERNAL"
+		ReenterEvent  brainy.EventType = "REENTER"
+	)
+
+	stateNodesValues := func(stateNodes []*brainy.StateNode) []string {
+		values := make([]string, 0, len(stateNodes))
+		for _, stateNode := range stateNodes {
+			values = append(values, stateNode.Value())
+		}
+
+		return values
+	}
+
+	testCases := []struct {
+		Event           brainy.EventType
+		ExpectedExited  []string
+		ExpectedEntered []string
+	}{
+		{
+			Event:           InternalEvent,
+			ExpectedExited:  []string{"(machine).compound.nested-a"},
+			ExpectedEntered: []string{"(machine).compound.nested-b"},
+		},
+		{
+			Event:           ExternalEvent,
+			ExpectedExited:  []string{"(machine).compound.nested-a", "(machine).compound"},
+			ExpectedEntered: []string{"(machine).compound", "(machine).compound.nested-b"},
+		},
+		{
+			Event:           ReenterEvent,
+			ExpectedExited:  []string{"(machine).compound.nested-a", "(machine).compound"},
+			ExpectedEntered: []string{"(machine).compound", "(machine).compound.nested-a"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(string(testCase.Event), func(t *testing.T) {
+			assert := assert.New(t)
+
+			stateMachine, err := brainy.NewMachine(brainy.StateNode{
+				Initial: CompoundState,
+
+				States: brainy.StateNodes{
+					CompoundState: &brainy.StateNode{
+						Initial: NestedAState,
+
+						States: brainy.StateNodes{
+							NestedAState: &brainy.StateNode{},
+
+							NestedBState: &brainy.StateNode{},
+						},
+
+						On: brainy.Events{
+							InternalEvent: brainy.Transition{
+								Target: brainy.ChildTarget(NestedBState),
+							},
+							ExternalEvent: brainy.Transition{
+								Target:   brainy.ChildTarget(NestedBState),
+								External: true,
+							},
+							ReenterEvent: CompoundState,
+						},
+					},
+				},
+			})
+			assert.NoError(err)
+
+			nextState, err := stateMachine.Send(testCase.Event)
+			assert.NoError(err)
+			assert.Equal(testCase.ExpectedExited, stateNodesValues(nextState.Exited))
+			assert.Equal(testCase.ExpectedEntered, stateNodesValues(nextState.Entered))
+		})
+	}
+}
+
+func TestForbiddenTransitionsBlockParentHandlers(t *testing.T) {
+	assert := assert.New(t)
+
+	compoundOnExitCalls := 0
+
+	stateMachine, err := brainy.NewMachine(brainy.StateNode{
+		Initial: CompoundState,
+
+		States: brainy.StateNodes{
+			CompoundState: &brainy.StateNode{
+				Initial: NestedAState,
+
+				States: brainy.StateNodes{
+					NestedAState: &brainy.StateNode{
+						On: brainy.Events{
+							ExitCompoundStateEvent: brainy.ForbiddenTransition{},
+							GoToNestedBStateEvent:  NestedBState,
+						},
+					},
+
+					NestedBState: &brainy.StateNode{},
+				},
+
+				OnExit: brainy.Actions{
+					brainy.ActionFn(func(c brainy.Context, e brainy.Event) error {
+						compoundOnExitCalls++
+						return nil
+					}),
+				},
+
+				On: brainy.Events{
+					ExitCompoundStateEvent: AtomicState,
+				},
+			},
+
+			AtomicState: &brainy.StateNode{},
+		},
+	})
+	assert.NoError(err)
+
+	assert.False(stateMachine.Can(ExitCompoundStateEvent))
+	assert.Equal([]brainy.EventType{GoToNestedBStateEvent}, stateMachine.NextEvents())
+
+	nextState, err := stateMachine.Send(ExitCompoundStateEvent)
+	assert.NoError(err)
+	assert.False(nextState.Changed)
+	assert.True(nextState.Matches(CompoundState, NestedAState))
+	assert.Equal(0, compoundOnExitCalls)
+
+	_, err = stateMachine.Send(GoToNestedBStateEvent)
+	assert.NoError(err)
+	assert.True(stateMachine.Can(ExitCompoundStateEvent))
+
+	nextState, err = stateMachine.Send(ExitCompoundStateEvent)
+	assert.NoError(err)
+	assert.True(nextState.Matches(AtomicState))
+	assert.Equal(1, compoundOnExitCalls)
+}
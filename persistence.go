@@ -0,0 +1,351 @@
+package brainy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrVersionConflict is returned by EventStore.AppendEvent when the version of the event does not follow
+// the version of the last event of the stream, usually because two state machines persist the same stream.
+var ErrVersionConflict = errors.New("version conflict")
+
+// ErrPersistence is returned when a stream could not be loaded by Repository.Load, and by Send when
+// the event was processed but could not be persisted.
+type ErrPersistence struct {
+	StreamID string
+	Err      error
+}
+
+func (err *ErrPersistence) Error() string {
+	return fmt.Sprintf("stream %q: %v", err.StreamID, err.Err)
+}
+
+func (err *ErrPersistence) Unwrap() error {
+	return err.Err
+}
+
+// A PersistedEvent is an event processed by a persisted state machine.
+type PersistedEvent struct {
+	// Version is the position of the event in its stream, starting at 1.
+	Version int       `json:"version"`
+	Time    time.Time `json:"time"`
+	// Event is the type of the event.
+	Event EventType `json:"event"`
+	// Payload is the event serialized as JSON. It is omitted when the event is an EventType.
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// A PersistedSnapshot is the state of a persisted state machine once the events of its stream have been
+// processed up to Version.
+type PersistedSnapshot struct {
+	Version int       `json:"version"`
+	Time    time.Time `json:"time"`
+	// State is the state value of the state machine, as returned by StateValue.String.
+	State string `json:"state"`
+	// Context is the context of the state machine serialized as JSON.
+	Context json.RawMessage `json:"context,omitempty"`
+	// Event is the type of the event that lead to the state.
+	Event EventType `json:"event"`
+	// Payload is the event that lead to the state serialized as JSON, if it is not an EventType.
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// An EventStore stores state machines as append-only streams of events, identified by a stream ID,
+// and the latest snapshot of each stream.
+//
+// Implementations must be safe for concurrent use.
+type EventStore interface {
+	// AppendEvent appends the event to the stream. Its version must follow the version of the last event
+	// of the stream, otherwise ErrVersionConflict is returned.
+	AppendEvent(streamID string, event PersistedEvent) error
+	// LoadEvents returns the events of the stream whose version is greater than afterVersion, in order.
+	LoadEvents(streamID string, afterVersion int) ([]PersistedEvent, error)
+	// SaveSnapshot replaces the snapshot of the stream.
+	SaveSnapshot(streamID string, snapshot PersistedSnapshot) error
+	// LoadSnapshot returns the snapshot of the stream, and false if none has been saved.
+	LoadSnapshot(streamID string) (PersistedSnapshot, bool, error)
+}
+
+// A ContextDecoder rebuilds a context from its JSON serialization.
+type ContextDecoder func(payload json.RawMessage) (Context, error)
+
+// JSONContextDecoder returns a decoder that unmarshals payloads into new values of the type of c.
+func JSONContextDecoder(c Context) ContextDecoder {
+	return func(payload json.RawMessage) (Context, error) {
+		return unmarshalLike(c, payload)
+	}
+}
+
+// A Repository loads the state machines of a definition from an EventStore, and persists the events
+// they process.
+//
+// Each event processed by a loaded state machine is appended to its stream, and a snapshot is saved
+// every SnapshotInterval events. A state machine is rebuilt by restoring the latest snapshot and sending
+// it the newer events, with side effects suppressed as with WithSuppressedSideEffects: only Assign, Send and
+// pure actions are run, and services are not invoked. Contexts and events must therefore be serializable
+// as JSON, and Assign and pure actions must only depend on the context and the event.
+type Repository struct {
+	definition       *Definition
+	store            EventStore
+	snapshotInterval int
+	eventDecoders    map[EventType]EventDecoder
+	contextDecoder   ContextDecoder
+}
+
+type RepositoryOption func(*Repository)
+
+// WithSnapshotInterval sets the number of events after which a snapshot is saved. It must be positive
+// and defaults to 100.
+func WithSnapshotInterval(interval int) RepositoryOption {
+	return func(repository *Repository) {
+		repository.snapshotInterval = interval
+	}
+}
+
+// WithPersistedEventDecoder sets the decoder of the persisted events of type eventType.
+// Events without a decoder are rebuilt as their EventType, and their payload is ignored.
+func WithPersistedEventDecoder(eventType EventType, decoder EventDecoder) RepositoryOption {
+	return func(repository *Repository) {
+		repository.eventDecoders[eventType] = decoder
+	}
+}
+
+// WithContextDecoder sets the decoder of the contexts of snapshots.
+// By default, contexts are unmarshaled into values of the type of the context of the root state node.
+func WithContextDecoder(decoder ContextDecoder) RepositoryOption {
+	return func(repository *Repository) {
+		repository.contextDecoder = decoder
+	}
+}
+
+// NewRepository returns a repository of the state machines of the definition, stored in the store.
+func NewRepository(definition *Definition, store EventStore, options ...RepositoryOption) *Repository {
+	repository := &Repository{
+		definition:       definition,
+		store:            store,
+		snapshotInterval: 100,
+		eventDecoders:    make(map[EventType]EventDecoder),
+	}
+	if rootContext := definition.root.Context; rootContext != nil {
+		repository.contextDecoder = JSONContextDecoder(rootContext)
+	}
+	for _, option := range options {
+		option(repository)
+	}
+
+	return repository
+}
+
+// Load returns the state machine of the stream, whose events are persisted as they are processed.
+//
+// If the stream is empty, a new state machine is created, and its initial actions are run. Otherwise
+// the state machine is rebuilt from the latest snapshot and the newer events, without side effects; once
+// rebuilt, the services invoked by its active state nodes are started. Events delayed by SendAfter actions
+// and children state machines are not restored.
+// Side effects are resumed once the state machine is rebuilt, even if WithSuppressedSideEffects is given.
+// Unless WithContextFactory is given, the state machine starts with its own copy of the context of the root
+// state node, or with the context of the snapshot.
+//
+// A stream must not be loaded by several state machines at once: the second one to persist an event
+// gets an ErrVersionConflict error, and is stopped, as its state is not the persisted one anymore.
+// The stream must be loaded again to send it other events.
+func (repository *Repository) Load(streamID string, options ...MachineOption) (*Machine, error) {
+	snapshot, hasSnapshot, err := repository.store.LoadSnapshot(streamID)
+	if err != nil {
+		return nil, &ErrPersistence{
+			StreamID: streamID,
+			Err:      fmt.Errorf("could not load snapshot: %w", err),
+		}
+	}
+
+	version := 0
+	if hasSnapshot {
+		version = snapshot.Version
+	}
+
+	events, err := repository.store.LoadEvents(streamID, version)
+	if err != nil {
+		return nil, &ErrPersistence{
+			StreamID: streamID,
+			Err:      fmt.Errorf("could not load events: %w", err),
+		}
+	}
+
+	var machine *Machine
+	switch {
+	case hasSnapshot:
+		machine, err = repository.restoreSnapshot(snapshot, options)
+	case len(events) == 0:
+		machine, err = repository.newMachine(options)
+	default:
+		machine, err = repository.newMachine(append([]MachineOption{WithSuppressedSideEffects(nil)}, options...))
+	}
+	if err != nil {
+		return nil, &ErrPersistence{
+			StreamID: streamID,
+			Err:      err,
+		}
+	}
+
+	for _, persistedEvent := range events {
+		event, err := decodeEvent(repository.eventDecoders, persistedEvent.Event, persistedEvent.Payload)
+		if err != nil {
+			return nil, &ErrPersistence{
+				StreamID: streamID,
+				Err:      fmt.Errorf("could not decode event %d: %w", persistedEvent.Version, err),
+			}
+		}
+
+		// Errors occured when the event was first processed, and are not reported again.
+		_, _ = machine.Send(event)
+
+		version = persistedEvent.Version
+	}
+
+	if !machine.disableLocking {
+		machine.lock.Lock()
+		defer machine.lock.Unlock()
+	}
+
+	machine.resumeSideEffects()
+	machine.stream = &persistedStream{
+		repository:        repository,
+		streamID:          streamID,
+		version:           version,
+		eventsSinceReload: len(events),
+	}
+
+	return machine, nil
+}
+
+// newMachine returns a new state machine of the definition. Its initial context is a copy of the context of
+// the root state node made with the ContextDecoder, so that the loaded state machines do not share it.
+func (repository *Repository) newMachine(options []MachineOption) (*Machine, error) {
+	if rootContext := repository.definition.root.Context; rootContext != nil && repository.contextDecoder != nil {
+		c, err := copyContext(rootContext, repository.contextDecoder)
+		if err != nil {
+			return nil, fmt.Errorf("could not copy the initial context: %w", err)
+		}
+
+		options = append([]MachineOption{WithContextFactory(func() Context {
+			return c
+		})}, options...)
+	}
+
+	return NewMachineFromDefinition(repository.definition, options...)
+}
+
+func (repository *Repository) restoreSnapshot(snapshot PersistedSnapshot, options []MachineOption) (*Machine, error) {
+	value, err := ParseStateValue(snapshot.State)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+
+	var c Context
+	if repository.contextDecoder != nil && len(snapshot.Context) > 0 {
+		if c, err = repository.contextDecoder(snapshot.Context); err != nil {
+			return nil, fmt.Errorf("%w: could not decode context: %v", ErrInvalidSnapshot, err)
+		}
+	}
+
+	event, err := decodeEvent(repository.eventDecoders, snapshot.Event, snapshot.Payload)
+	if err != nil {
+		return nil, fmt.Errorf("%w: could not decode event: %v", ErrInvalidSnapshot, err)
+	}
+
+	return NewMachineFromSnapshot(repository.definition, Snapshot{
+		Value:   value,
+		Context: c,
+		Event:   event,
+	}, append([]MachineOption{WithSuppressedSideEffects(nil)}, options...)...)
+}
+
+// persistedStream persists the events processed by a state machine loaded by a Repository.
+type persistedStream struct {
+	repository *Repository
+	streamID   string
+	// version is the version of the last persisted event.
+	version int
+	// eventsSinceReload counts the events since the last snapshot, including the ones replayed.
+	eventsSinceReload int
+}
+
+// persist appends the event to the stream, and saves a snapshot of the state when the snapshot interval
+// is reached. It can be called on a nil stream.
+//
+// Events rejected because no transition could handle them are not appended: they did not change the state,
+// and rebuilding the state machine would only reject them again.
+func (stream *persistedStream) persist(now time.Time, event Event, state State, err error) error {
+	if stream == nil || isRejectedEvent(state, err) {
+		return nil
+	}
+
+	payload, err := encodeEventPayload(event)
+	if err != nil {
+		return &ErrPersistence{
+			StreamID: stream.streamID,
+			Err:      err,
+		}
+	}
+
+	persistedEvent := PersistedEvent{
+		Version: stream.version + 1,
+		Time:    now,
+		Event:   event.eventType(),
+		Payload: payload,
+	}
+	if err := stream.repository.store.AppendEvent(stream.streamID, persistedEvent); err != nil {
+		return &ErrPersistence{
+			StreamID: stream.streamID,
+			Err:      fmt.Errorf("could not append event %d: %w", persistedEvent.Version, err),
+		}
+	}
+
+	stream.version = persistedEvent.Version
+	stream.eventsSinceReload++
+
+	if stream.eventsSinceReload < stream.repository.snapshotInterval {
+		return nil
+	}
+
+	if err := stream.saveSnapshot(now, state); err != nil {
+		return &ErrPersistence{
+			StreamID: stream.streamID,
+			Err:      fmt.Errorf("could not save snapshot %d: %w", stream.version, err),
+		}
+	}
+	stream.eventsSinceReload = 0
+
+	return nil
+}
+
+// isRejectedEvent returns whether the event was rejected without changing the state, because no handler
+// or no transition whose guard passes could be found.
+func isRejectedEvent(state State, err error) bool {
+	var noHandlerErr *ErrNoHandlerToHandleEvent
+
+	return !state.Changed && (errors.As(err, &noHandlerErr) || errors.Is(err, ErrNoTransitionCouldBeRun))
+}
+
+func (stream *persistedStream) saveSnapshot(now time.Time, state State) error {
+	c, err := json.Marshal(state.Context)
+	if err != nil {
+		return err
+	}
+
+	payload, err := encodeEventPayload(state.Event)
+	if err != nil {
+		return err
+	}
+
+	return stream.repository.store.SaveSnapshot(stream.streamID, PersistedSnapshot{
+		Version: stream.version,
+		Time:    now,
+		State:   state.Value.String(),
+		Context: c,
+		Event:   state.Event.eventType(),
+		Payload: payload,
+	})
+}
@@ -0,0 +1,213 @@
+package brainy_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Devessier/brainy"
+	"github.com/stretchr/testify/assert"
+)
+
+func persistedOrderMachineDefinition(t *testing.T, checkouts *int) *brainy.Definition {
+	config := orderMachineConfig()
+	config.States[OrderCartState].On[OrderCheckoutEvent] = brainy.Transition{
+		Target: OrderAwaitingPaymentState,
+		Actions: brainy.Actions{
+			brainy.ActionFn(func(c brainy.Context, e brainy.Event) error {
+				*checkouts++
+
+				return nil
+			}),
+		},
+	}
+
+	definition, err := brainy.NewDefinition(config)
+	assert.NoError(t, err)
+
+	return definition
+}
+
+func TestRepositoryPersistsAndRebuildsMachines(t *testing.T) {
+	fileStore, err := brainy.NewFileEventStore(t.TempDir())
+	assert.NoError(t, err)
+
+	stores := map[string]brainy.EventStore{
+		"memory": brainy.NewMemoryEventStore(),
+		"file":   fileStore,
+	}
+
+	for name, store := range stores {
+		store := store
+
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			checkouts := 0
+			repository := brainy.NewRepository(
+				persistedOrderMachineDefinition(t, &checkouts),
+				store,
+				brainy.WithSnapshotInterval(2),
+			)
+
+			machine, err := repository.Load("order-1")
+			assert.NoError(err)
+
+			for _, event := range []brainy.EventType{OrderAddItemEvent, OrderAddItemEvent, OrderCheckoutEvent} {
+				_, err := machine.Send(event)
+				assert.NoError(err)
+			}
+			assert.Equal(1, checkouts)
+
+			snapshot, ok, err := store.LoadSnapshot("order-1")
+			assert.NoError(err)
+			assert.True(ok)
+			assert.Equal(2, snapshot.Version)
+			assert.Equal("cart", snapshot.State)
+			assert.JSONEq(`{"Items":2}`, string(snapshot.Context))
+
+			events, err := store.LoadEvents("order-1", 2)
+			assert.NoError(err)
+			if assert.Len(events, 1) {
+				assert.Equal(3, events[0].Version)
+				assert.Equal(OrderCheckoutEvent, events[0].Event)
+			}
+
+			rebuiltMachine, err := repository.Load("order-1")
+			assert.NoError(err)
+
+			assert.True(rebuiltMachine.State().Matches(OrderAwaitingPaymentState))
+			assert.Equal(OrderContext{Items: 2}, rebuiltMachine.Context())
+			// Side effects are not run again while the state machine is rebuilt.
+			assert.Equal(1, checkouts)
+
+			state, err := rebuiltMachine.Send(OrderPayEvent)
+			assert.NoError(err)
+			assert.True(state.Matches(OrderPaidState))
+
+			// The stream has been extended by the rebuilt state machine.
+			_, err = machine.Send(OrderPayEvent)
+			assert.ErrorIs(err, brainy.ErrVersionConflict)
+
+			var persistenceErr *brainy.ErrPersistence
+			if assert.True(errors.As(err, &persistenceErr)) {
+				assert.Equal("order-1", persistenceErr.StreamID)
+			}
+
+			// The stale state machine is stopped, so that it does not move further away from the stream.
+			_, err = machine.Send(OrderAddItemEvent)
+			assert.ErrorIs(err, brainy.ErrMachineStopped)
+
+			events, err = store.LoadEvents("order-1", 0)
+			assert.NoError(err)
+			assert.Len(events, 4)
+
+			otherMachine, err := repository.Load("order-2")
+			assert.NoError(err)
+			assert.True(otherMachine.State().Matches(OrderCartState))
+		})
+	}
+}
+
+func TestRepositoryRebuildsMachinesWithoutSnapshot(t *testing.T) {
+	assert := assert.New(t)
+
+	store := brainy.NewMemoryEventStore()
+	checkouts := 0
+	repository := brainy.NewRepository(persistedOrderMachineDefinition(t, &checkouts), store)
+
+	machine, err := repository.Load("order")
+	assert.NoError(err)
+
+	for _, event := range []brainy.EventType{OrderAddItemEvent, OrderCheckoutEvent} {
+		_, err := machine.Send(event)
+		assert.NoError(err)
+	}
+
+	_, ok, err := store.LoadSnapshot("order")
+	assert.NoError(err)
+	assert.False(ok)
+
+	rebuiltMachine, err := repository.Load("order")
+	assert.NoError(err)
+
+	assert.True(rebuiltMachine.State().Matches(OrderAwaitingPaymentState))
+	assert.Equal(OrderContext{Items: 1}, rebuiltMachine.Context())
+	assert.Equal(1, checkouts)
+}
+
+func TestRepositoryGivesEachMachineItsOwnContext(t *testing.T) {
+	assert := assert.New(t)
+
+	definition, err := brainy.NewDefinition(brainy.StateNode{
+		Initial: OnState,
+		Context: &CounterContext{},
+
+		States: brainy.StateNodes{
+			OnState: &brainy.StateNode{
+				On: brainy.Events{
+					IncrementEventType: brainy.Transition{
+						Actions: brainy.Actions{
+							brainy.PureActionFn(func(c brainy.Context, e brainy.Event) error {
+								c.(*CounterContext).Count++
+
+								return nil
+							}),
+						},
+					},
+				},
+			},
+		},
+	})
+	assert.NoError(err)
+
+	repository := brainy.NewRepository(definition, brainy.NewMemoryEventStore())
+
+	firstMachine, err := repository.Load("one")
+	assert.NoError(err)
+	secondMachine, err := repository.Load("two")
+	assert.NoError(err)
+
+	for i := 0; i < 2; i++ {
+		_, err := firstMachine.Send(IncrementEventType)
+		assert.NoError(err)
+	}
+	assert.Equal(&CounterContext{Count: 2}, firstMachine.Context())
+	assert.Equal(&CounterContext{Count: 0}, secondMachine.Context())
+
+	rebuiltMachine, err := repository.Load("one")
+	assert.NoError(err)
+	assert.Equal(&CounterContext{Count: 2}, rebuiltMachine.Context())
+	assert.Equal(&CounterContext{Count: 2}, firstMachine.Context())
+
+	factoryMachine, err := repository.Load("three", brainy.WithContextFactory(func() brainy.Context {
+		return &CounterContext{Count: 10}
+	}))
+	assert.NoError(err)
+	assert.Equal(&CounterContext{Count: 10}, factoryMachine.Context())
+}
+
+func TestRepositoryDoesNotPersistRejectedEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	store := brainy.NewMemoryEventStore()
+	checkouts := 0
+	repository := brainy.NewRepository(persistedOrderMachineDefinition(t, &checkouts), store)
+
+	machine, err := repository.Load("order")
+	assert.NoError(err)
+
+	_, err = machine.Send(OrderPayEvent)
+	assert.ErrorIs(err, &brainy.ErrNoHandlerToHandleEvent{
+		Event: OrderPayEvent,
+	})
+
+	_, err = machine.Send(OrderAddItemEvent)
+	assert.NoError(err)
+
+	events, err := store.LoadEvents("order", 0)
+	assert.NoError(err)
+	if assert.Len(events, 1) {
+		assert.Equal(1, events[0].Version)
+		assert.Equal(OrderAddItemEvent, events[0].Event)
+	}
+}
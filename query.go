@@ -0,0 +1,79 @@
+package brainy
+
+import "sort"
+
+// Can returns whether the event would be accepted by the state machine in its current state,
+// that is, whether a state node handles the event and one of its transitions can be taken.
+//
+// Can does not transition the state machine nor run any action. Guards are evaluated, so they
+// should not have side effects.
+func (machine *Machine) Can(event Event) bool {
+	if !machine.disableLocking {
+		machine.lock.Lock()
+		defer machine.lock.Unlock()
+	}
+
+	if machine.stopped {
+		return false
+	}
+
+	_, _, eventHandler := resolveStateNodeWithHandler(machine.current, event.eventType())
+	if eventHandler == nil || isForbiddenTransition(eventHandler) {
+		return false
+	}
+
+	_, _, _, err := selectTransition(machine.current, machine.context, eventHandler.transitions(), event)
+
+	return err == nil
+}
+
+// NextEvents returns the types of the events handled by the current state node and its ancestors, sorted
+// alphabetically.
+// Each event type is resolved as Send would resolve it: events forbidden by a ForbiddenTransition are not listed,
+// even if an ancestor handles them, and this includes events forbidden by a descriptor, such as `*`.
+// Descriptors ending with a wildcard, such as `*` and `error.*`, are not event types and are never listed.
+// Other descriptors, such as `done.invoke`, are listed, as they are also the event types they match exactly.
+// Guards are not evaluated: an event can be listed even if none of its transitions can be taken.
+// Use Can to know whether an event would be accepted.
+func (machine *Machine) NextEvents() []EventType {
+	if !machine.disableLocking {
+		machine.lock.Lock()
+		defer machine.lock.Unlock()
+	}
+
+	return nextEvents(machine.current)
+}
+
+// NextEvents returns the types of the events handled by the state node of the state and its ancestors.
+// See Machine.NextEvents.
+func (s State) NextEvents() []EventType {
+	return nextEvents(s.StateNode)
+}
+
+func nextEvents(current *StateNode) []EventType {
+	encounteredEvents := make(map[EventType]bool)
+	nextEvents := make([]EventType, 0)
+
+	for stateNode := current; stateNode != nil; stateNode = stateNode.parentStateNode {
+		for eventType := range stateNode.On {
+			if encounteredEvents[eventType] || isWildcardDescriptor(eventType) {
+				continue
+			}
+			encounteredEvents[eventType] = true
+
+			// A more nested state node can handle the event with a descriptor.
+			_, _, eventHandler := resolveStateNodeWithHandler(current, eventType)
+			if eventHandler == nil || isForbiddenTransition(eventHandler) {
+				continue
+			}
+
+			nextEvents = append(nextEvents, eventType)
+		}
+	}
+
+	sort.Slice(nextEvents, func(i, j int) bool {
+		return nextEvents[i] < nextEvents[j]
+	})
+
+	return nextEvents
+}
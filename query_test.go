@@ -0,0 +1,71 @@
+package brainy_test
+
+import (
+	"testing"
+
+	"github.com/Devessier/brainy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanAndNextEvents(t *testing.T) {
+	assert := assert.New(t)
+
+	const (
+		ResetEvent  brainy.EventType = "RESET"
+		ToggleEvent brainy.EventType = "TOGGLE"
+	)
+
+	isLocked := true
+	onEntryCalls := 0
+
+	stateMachine, err := brainy.NewMachine(brainy.StateNode{
+		Initial: CompoundState,
+
+		States: brainy.StateNodes{
+			CompoundState: &brainy.StateNode{
+				Initial: OffState,
+
+				States: brainy.StateNodes{
+					OffState: &brainy.StateNode{
+						On: brainy.Events{
+							ToggleEvent: brainy.Transition{
+								Cond: func(c brainy.Context, e brainy.Event) bool {
+									return !isLocked
+								},
+								Target: OnState,
+							},
+						},
+					},
+
+					OnState: &brainy.StateNode{
+						OnEntry: brainy.Actions{
+							brainy.ActionFn(func(c brainy.Context, e brainy.Event) error {
+								onEntryCalls++
+								return nil
+							}),
+						},
+					},
+				},
+
+				On: brainy.Events{
+					ResetEvent: CompoundState,
+				},
+			},
+		},
+	})
+	assert.NoError(err)
+
+	assert.Equal([]brainy.EventType{ResetEvent, ToggleEvent}, stateMachine.NextEvents())
+	assert.Equal(stateMachine.NextEvents(), stateMachine.State().NextEvents())
+
+	assert.False(stateMachine.Can(ToggleEvent))
+	assert.True(stateMachine.Can(ResetEvent))
+	assert.False(stateMachine.Can(UnknownEventType))
+
+	isLocked = false
+	assert.True(stateMachine.Can(ToggleEvent))
+
+	// Can must not have any side effect.
+	assert.True(stateMachine.Current().Matches(CompoundState, OffState))
+	assert.Equal(0, onEntryCalls)
+}
@@ -30,3 +30,24 @@ func (q *eventsQueue) Poll() (Event, bool) {
 
 	return event, true
 }
+
+// Len returns the count of events waiting in the queue.
+func (q *eventsQueue) Len() int {
+	return len(q.events)
+}
+
+// Events returns a copy of the events waiting in the queue, in the order they will be polled.
+func (q *eventsQueue) Events() []Event {
+	events := make([]Event, len(q.events))
+	copy(events, q.events)
+
+	return events
+}
+
+// Clear removes all events from the queue and returns them.
+func (q *eventsQueue) Clear() []Event {
+	events := q.events
+	q.events = make([]Event, 0)
+
+	return events
+}
@@ -0,0 +1,204 @@
+package brainy
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// ErrInvalidEventLog is returned by Replayer.Replay when a line of the event log can not be read.
+var ErrInvalidEventLog = errors.New("invalid event log")
+
+// ErrReplayDivergence is returned by Replayer.Replay when replaying an event does not lead to the state
+// recorded in the event log. It describes the first divergence.
+type ErrReplayDivergence struct {
+	// Line is the line of the event log, starting at 1.
+	Line  int
+	Entry EventLogEntry
+	// State is the state value reached by the replayed state machine.
+	State string
+	// Err is the error returned while the event was replayed, if any.
+	Err error
+}
+
+func (err *ErrReplayDivergence) Error() string {
+	expected, actual := err.Entry.State, err.State
+	if err.Entry.Error != "" {
+		expected += fmt.Sprintf(" (error: %s)", err.Entry.Error)
+	}
+	if err.Err != nil {
+		actual += fmt.Sprintf(" (error: %v)", err.Err)
+	}
+
+	return fmt.Sprintf(
+		"replay diverged at line %d on event %s: expected %s, got %s",
+		err.Line,
+		err.Entry.Event,
+		expected,
+		actual,
+	)
+}
+
+func (err *ErrReplayDivergence) Unwrap() error {
+	return err.Err
+}
+
+// An EventDecoder rebuilds an event from the payload recorded in an event log.
+type EventDecoder func(payload json.RawMessage) (Event, error)
+
+// JSONEventDecoder returns a decoder that unmarshals payloads into new values of the type of event,
+// which must be a struct event such as the ones sent to the state machine.
+//
+//  replayer := NewReplayer(definition, WithEventDecoder(AddUserEventType, JSONEventDecoder(AddUserEvent{})))
+func JSONEventDecoder(event Event) EventDecoder {
+	return func(payload json.RawMessage) (Event, error) {
+		decodedEvent, err := unmarshalLike(event, payload)
+		if err != nil {
+			return nil, err
+		}
+
+		return decodedEvent.(Event), nil
+	}
+}
+
+// unmarshalLike unmarshals the payload into a new value of the type of model.
+func unmarshalLike(model interface{}, payload json.RawMessage) (interface{}, error) {
+	value := reflect.New(reflect.TypeOf(model))
+	if err := json.Unmarshal(payload, value.Interface()); err != nil {
+		return nil, err
+	}
+
+	return value.Elem().Interface(), nil
+}
+
+// copyContext returns a copy of the context, encoded as JSON and decoded with decode,
+// so that state machines rebuilt from a definition do not share the context of its root state node.
+func copyContext(c Context, decode ContextDecoder) (Context, error) {
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return decode(payload)
+}
+
+// decodeEvent rebuilds an event of type eventType with the decoder registered for its type.
+// Events without a decoder or without payload are rebuilt as their EventType.
+func decodeEvent(decoders map[EventType]EventDecoder, eventType EventType, payload json.RawMessage) (Event, error) {
+	decoder, ok := decoders[eventType]
+	if !ok || len(payload) == 0 {
+		return eventType, nil
+	}
+
+	return decoder(payload)
+}
+
+// A Replayer rebuilds state machines from a definition and replays event logs written by an EventRecorder.
+type Replayer struct {
+	definition     *Definition
+	decoders       map[EventType]EventDecoder
+	machineOptions []MachineOption
+}
+
+type ReplayerOption func(*Replayer)
+
+// WithEventDecoder sets the decoder of the events of type eventType.
+// Events without a decoder are replayed as their EventType, and their payload is ignored.
+func WithEventDecoder(eventType EventType, decoder EventDecoder) ReplayerOption {
+	return func(replayer *Replayer) {
+		replayer.decoders[eventType] = decoder
+	}
+}
+
+// WithReplayMachineOptions sets options of the replayed state machines.
+func WithReplayMachineOptions(options ...MachineOption) ReplayerOption {
+	return func(replayer *Replayer) {
+		replayer.machineOptions = append(replayer.machineOptions, options...)
+	}
+}
+
+// NewReplayer returns a replayer of the state machines of the definition.
+func NewReplayer(definition *Definition, options ...ReplayerOption) *Replayer {
+	replayer := &Replayer{
+		definition: definition,
+		decoders:   make(map[EventType]EventDecoder),
+	}
+	for _, option := range options {
+		option(replayer)
+	}
+
+	return replayer
+}
+
+// Replay builds a state machine from the definition and sends it the events of the log, in order.
+// After each event, the state value and the failure of the event are compared to the ones recorded;
+// the first divergence is returned as an ErrReplayDivergence.
+// The state machine is returned in the state it reached, even if an error is returned.
+//
+// The replayed state machine does not affect the outside world, as with WithSuppressedSideEffects: only Assign,
+// Send and pure actions are run, and services are not invoked. A stub replacing the other actions can be given
+// with WithReplayMachineOptions. Events sent by services and delayed events are replayed from the log,
+// at their place, as they are recorded when they are processed.
+// As the other actions are not run again, an event whose actions failed when it was recorded is reported
+// as a divergence.
+//
+// Each replayed state machine starts with a copy of the context of the root state node, made through JSON,
+// as pure actions may update it in place. A context that can not be copied this way must be given with
+// WithReplayMachineOptions and WithContextFactory.
+func (replayer *Replayer) Replay(log io.Reader) (*Machine, error) {
+	options := []MachineOption{WithSuppressedSideEffects(nil)}
+	if rootContext := replayer.definition.root.Context; rootContext != nil {
+		c, err := copyContext(rootContext, JSONContextDecoder(rootContext))
+		if err != nil {
+			return nil, fmt.Errorf("could not copy the initial context: %w", err)
+		}
+
+		options = append(options, WithContextFactory(func() Context {
+			return c
+		}))
+	}
+	options = append(options, replayer.machineOptions...)
+
+	machine, err := NewMachineFromDefinition(replayer.definition, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := bufio.NewScanner(log)
+	scanner.Buffer(nil, 1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var entry EventLogEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return machine, fmt.Errorf("%w: line %d: %v", ErrInvalidEventLog, line, err)
+		}
+
+		event, err := decodeEvent(replayer.decoders, entry.Event, entry.Payload)
+		if err != nil {
+			return machine, fmt.Errorf("%w: line %d: could not decode event %s: %v", ErrInvalidEventLog, line, entry.Event, err)
+		}
+
+		state, err := machine.Send(event)
+		if stateValue := state.Value.String(); stateValue != entry.State || (err != nil) != (entry.Error != "") {
+			return machine, &ErrReplayDivergence{
+				Line:  line,
+				Entry: entry,
+				State: stateValue,
+				Err:   err,
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return machine, fmt.Errorf("%w: %v", ErrInvalidEventLog, err)
+	}
+
+	return machine, nil
+}
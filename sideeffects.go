@@ -0,0 +1,77 @@
+package brainy
+
+// WithSuppressedSideEffects transitions the state machine without affecting the outside world, which is useful
+// to replay, migrate or simulate state machines.
+//
+// Assign and Send actions are run, as well as actions declared pure with PureActionFn. Other actions created
+// with ActionFn are replaced by the stub, which can be nil to skip them. Events sent to other state machines,
+// spawned children, delayed events and invoked services are skipped.
+//
+// Side effects are suppressed until ResumeSideEffects is called.
+func WithSuppressedSideEffects(stub Action) MachineOption {
+	return func(machine *Machine) {
+		machine.suppressSideEffects = true
+		machine.sideEffectStub = stub
+	}
+}
+
+// PureActionFn returns an Actioner that runs the function, and that declares that the function has no side effect:
+// it only reads or updates the context. Pure actions are run even when side effects are suppressed,
+// so that contexts updated in place remain correct when state machines are replayed.
+func PureActionFn(fn Action) Actioner {
+	return actionFn{
+		Fn:   fn,
+		pure: true,
+	}
+}
+
+// hasSideEffects returns whether running the actioner can affect something else than the state machine itself.
+func hasSideEffects(actioner Actioner) bool {
+	switch action := actioner.(type) {
+	case assignAction, sendActionEvent:
+		return false
+	case actionFn:
+		return !action.pure
+	default:
+		return true
+	}
+}
+
+// runSuppressedAction runs the stub in place of the actioner, if the actioner is an action created with ActionFn.
+func (machine *Machine) runSuppressedAction(actioner Actioner, c Context, e Event) error {
+	if _, isActionFn := actioner.(actionFn); !isActionFn || machine.sideEffectStub == nil {
+		return nil
+	}
+
+	return machine.sideEffectStub(c, e)
+}
+
+// ResumeSideEffects lets a state machine created with WithSuppressedSideEffects affect the outside world again,
+// and starts the services invoked by its active state nodes.
+// Actions that were skipped while side effects were suppressed are not run.
+func (machine *Machine) ResumeSideEffects() {
+	if !machine.disableLocking {
+		machine.lock.Lock()
+		defer machine.lock.Unlock()
+	}
+
+	machine.resumeSideEffects()
+}
+
+// resumeSideEffects lets the state machine affect the outside world again.
+// The caller is responsible for locking the state machine.
+func (machine *Machine) resumeSideEffects() {
+	if !machine.suppressSideEffects {
+		return
+	}
+	machine.suppressSideEffects = false
+	machine.sideEffectStub = nil
+
+	if machine.stopped {
+		return
+	}
+
+	for _, activeStateNode := range statesToEnter(machine.current, nil) {
+		machine.startInvocations(activeStateNode, machine.context, machine.state.Event)
+	}
+}
@@ -0,0 +1,144 @@
+package brainy_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Devessier/brainy"
+	"github.com/Devessier/brainy/brainytest"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	SimulationDraftState     brainy.StateType = "draft"
+	SimulationSubmittedState brainy.StateType = "submitted"
+	SimulationReviewedState  brainy.StateType = "reviewed"
+	SimulationExpiredState   brainy.StateType = "expired"
+
+	SimulationSubmitEvent brainy.EventType = "SUBMIT"
+	SimulationReviewEvent brainy.EventType = "REVIEW"
+	SimulationExpireEvent brainy.EventType = "EXPIRE"
+)
+
+type SimulationContext struct {
+	Revisions int
+	Submitted bool
+}
+
+func simulationMachineConfig(recorder *brainytest.Recorder, invoked chan<- struct{}) brainy.StateNode {
+	return brainy.StateNode{
+		Initial: SimulationDraftState,
+		Context: &SimulationContext{},
+
+		States: brainy.StateNodes{
+			SimulationDraftState: &brainy.StateNode{
+				On: brainy.Events{
+					SimulationSubmitEvent: brainy.Transition{
+						Target: SimulationSubmittedState,
+						Actions: brainy.Actions{
+							brainy.PureActionFn(func(c brainy.Context, e brainy.Event) error {
+								c.(*SimulationContext).Revisions++
+
+								return nil
+							}),
+							brainy.Assign(func(c brainy.Context, e brainy.Event) brainy.Context {
+								simulationContext := *c.(*SimulationContext)
+								simulationContext.Submitted = true
+
+								return &simulationContext
+							}),
+							recorder.Action("notifyReviewers"),
+						},
+					},
+				},
+			},
+
+			SimulationSubmittedState: &brainy.StateNode{
+				OnEntry: brainy.Actions{
+					brainy.SendAfter(time.Hour, SimulationExpireEvent),
+					brainy.Send(SimulationReviewEvent),
+				},
+
+				Invoke: brainy.Invokes{
+					{
+						ID: "archive",
+						Src: func(ctx context.Context, c brainy.Context, e brainy.Event) (interface{}, error) {
+							invoked <- struct{}{}
+							<-ctx.Done()
+
+							return nil, nil
+						},
+					},
+				},
+
+				On: brainy.Events{
+					SimulationReviewEvent: SimulationReviewedState,
+					SimulationExpireEvent: SimulationExpiredState,
+				},
+			},
+
+			SimulationReviewedState: &brainy.StateNode{
+				Invoke: brainy.Invokes{
+					{
+						ID: "publish",
+						Src: func(ctx context.Context, c brainy.Context, e brainy.Event) (interface{}, error) {
+							invoked <- struct{}{}
+							<-ctx.Done()
+
+							return nil, nil
+						},
+					},
+				},
+
+				On: brainy.Events{
+					SimulationExpireEvent: SimulationExpiredState,
+				},
+			},
+
+			SimulationExpiredState: &brainy.StateNode{},
+		},
+	}
+}
+
+func TestSuppressedSideEffects(t *testing.T) {
+	assert := assert.New(t)
+
+	recorder := brainytest.NewRecorder()
+	invoked := make(chan struct{}, 2)
+	stubbedEvents := make([]brainy.Event, 0)
+	stub := func(c brainy.Context, e brainy.Event) error {
+		stubbedEvents = append(stubbedEvents, e)
+
+		return nil
+	}
+
+	machine, clock := brainytest.NewMachine(
+		t,
+		simulationMachineConfig(recorder, invoked),
+		brainy.WithSuppressedSideEffects(stub),
+	)
+
+	brainytest.AssertTransition(t, machine, SimulationSubmitEvent, "reviewed")
+	assert.Equal(&SimulationContext{Revisions: 1, Submitted: true}, machine.Context())
+
+	// The action with side effects is replaced by the stub.
+	brainytest.ExpectActions(t, recorder)
+	assert.Equal([]brainy.Event{SimulationSubmitEvent}, stubbedEvents)
+
+	// Delayed events and invoked services are skipped.
+	clock.AdvanceTime(2 * time.Hour)
+	brainytest.AssertState(t, machine, "reviewed")
+	assert.Len(invoked, 0)
+
+	machine.ResumeSideEffects()
+
+	// Services of active state nodes are started once side effects are resumed.
+	select {
+	case <-invoked:
+	case <-time.After(time.Second):
+		assert.Fail("service of active state node has not been invoked")
+	}
+
+	brainytest.AssertTransition(t, machine, SimulationExpireEvent, "expired")
+}
@@ -0,0 +1,101 @@
+package brainy
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidSnapshot is returned when a snapshot does not describe an atomic state node of the definition.
+var ErrInvalidSnapshot = errors.New("invalid snapshot")
+
+// A Snapshot describes what a state machine needs to be restored: its active state nodes, its context
+// and the event that lead to them.
+type Snapshot struct {
+	Value   StateValue
+	Context Context
+	Event   Event
+}
+
+// Snapshot returns the snapshot of the state.
+func (s State) Snapshot() Snapshot {
+	return Snapshot{
+		Value:   s.Value,
+		Context: s.Context,
+		Event:   s.Event,
+	}
+}
+
+// Snapshot returns the snapshot of the current state of the state machine.
+func (machine *Machine) Snapshot() Snapshot {
+	return machine.State().Snapshot()
+}
+
+// NewMachineFromSnapshot returns a Machine that runs the given definition, restored from the snapshot.
+//
+// The state machine is not initialized: no action is run, neither the initial ones nor the entry actions
+// of the restored state nodes. Services invoked by the restored state nodes are started, but
+// events delayed by SendAfter actions and children state machines are not restored.
+func NewMachineFromSnapshot(definition *Definition, snapshot Snapshot, options ...MachineOption) (*Machine, error) {
+	stateNode, err := definition.resolveSnapshot(snapshot)
+	if err != nil {
+		return nil, err
+	}
+
+	machine := newUninitializedMachine(definition, options...)
+
+	if !machine.disableLocking {
+		machine.lock.Lock()
+	}
+	machine.restore(stateNode, snapshot.Context, snapshot.Event)
+	machine.history.recordStep(machine.state)
+	if !machine.disableLocking {
+		machine.lock.Unlock()
+	}
+
+	return machine, nil
+}
+
+// resolveSnapshot returns the atomic state node described by the state value of the snapshot.
+func (definition *Definition) resolveSnapshot(snapshot Snapshot) (*StateNode, error) {
+	branches := snapshot.Value.branches()
+	if len(branches) > 1 {
+		return nil, fmt.Errorf("%w: %d state nodes are active in %q", ErrInvalidSnapshot, len(branches), snapshot.Value.String())
+	}
+
+	stateNode, err := definition.root.followPath(snapshot.Value.String())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+
+	if !stateNode.isAtomic() {
+		return nil, fmt.Errorf("%w: %s is not an atomic state node", ErrInvalidSnapshot, stateNode.id)
+	}
+
+	return stateNode, nil
+}
+
+// restore replaces the state of the state machine without running any action.
+// Invoked services, delayed events and children state machines of the previous state nodes are stopped,
+// and the services invoked by the restored state nodes are started.
+// The caller is responsible for locking the state machine.
+func (machine *Machine) restore(stateNode *StateNode, c Context, e Event) {
+	for invokingStateNode := range machine.invocations {
+		machine.cancelInvocations(invokingStateNode)
+	}
+
+	for owner := range machine.delayedEvents {
+		machine.cancelDelayedEvents(owner)
+	}
+
+	machine.stopAllChildren()
+	machine.externalEvents.Clear()
+
+	machine.previous = machine.current
+	machine.current = stateNode
+	machine.context = c
+	machine.state = newState(stateNode, stateNode, c, e, newMacrostepRecord())
+
+	for _, activeStateNode := range statesToEnter(stateNode, nil) {
+		machine.startInvocations(activeStateNode, c, e)
+	}
+}
@@ -0,0 +1,238 @@
+package brainy
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidStateValue is returned by ParseStateValue when the string does not describe a state value.
+var ErrInvalidStateValue = errors.New("invalid state value")
+
+// StateValue describes the active state nodes of a state machine as a tree.
+// Each key is the name of an active state node, and holds the state value of its active children.
+// Atomic state nodes hold a nil StateValue.
+//
+// Given that the id of the active StateNode is `compound.atomic`, its state value is:
+//  StateValue{
+//  	CompoundState: StateValue{
+//  		AtomicState: nil,
+//  	},
+//  }
+//
+// A state node with several active children, such as a parallel state node, holds one key per active child.
+type StateValue map[StateType]StateValue
+
+// ParseStateValue parses a dotted state string, such as `compound.atomic`, into a StateValue.
+// Several branches can be given, separated by commas: `parallel.a.b, parallel.c` is parsed into a StateValue
+// in which parallel holds both a and c.
+func ParseStateValue(value string) (StateValue, error) {
+	stateValue := StateValue{}
+
+	for _, branch := range strings.Split(value, ",") {
+		segments := strings.Split(strings.TrimSpace(branch), ".")
+
+		node := stateValue
+		for index, segment := range segments {
+			if segment == "" {
+				return nil, fmt.Errorf("%w: empty state name in %q", ErrInvalidStateValue, value)
+			}
+
+			stateType := StateType(segment)
+			childValue, ok := node[stateType]
+
+			if isLastSegment := index == len(segments)-1; isLastSegment {
+				if !ok {
+					node[stateType] = nil
+				}
+
+				break
+			}
+
+			if childValue == nil {
+				childValue = StateValue{}
+				node[stateType] = childValue
+			}
+
+			node = childValue
+		}
+	}
+
+	return stateValue, nil
+}
+
+// Matches returns whether the state value contains the partial hierarchy described by parentValue.
+// Each state node of parentValue must be active in the state value; state nodes that are not
+// described by parentValue are ignored.
+//
+//  value := StateValue{"compound": StateValue{"atomic": nil}}
+//
+//  value.Matches(StateValue{"compound": nil})
+//  // => true
+//
+//  value.Matches(StateValue{"compound": StateValue{"other": nil}})
+//  // => false
+func (v StateValue) Matches(parentValue StateValue) bool {
+	for stateType, childParentValue := range parentValue {
+		childValue, ok := v[stateType]
+		if !ok {
+			return false
+		}
+
+		if !childValue.Matches(childParentValue) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// String returns the state value as a dotted string, such as `compound.atomic`.
+// When several state nodes are active at the same level, each branch is described, sorted alphabetically
+// and separated by commas, so that the result can be parsed by ParseStateValue.
+func (v StateValue) String() string {
+	return strings.Join(v.branches(), ", ")
+}
+
+func (v StateValue) branches() []string {
+	stateTypes := make([]string, 0, len(v))
+	for stateType := range v {
+		stateTypes = append(stateTypes, stateType.String())
+	}
+	sort.Strings(stateTypes)
+
+	branches := make([]string, 0)
+	for _, stateType := range stateTypes {
+		childBranches := v[StateType(stateType)].branches()
+		if len(childBranches) == 0 {
+			branches = append(branches, stateType)
+			continue
+		}
+
+		for _, childBranch := range childBranches {
+			branches = append(branches, stateType+"."+childBranch)
+		}
+	}
+
+	return branches
+}
+
+// stateValueOf returns the state value of a state machine whose active atomic state node is stateNode.
+func stateValueOf(stateNode *StateNode) StateValue {
+	var value StateValue
+
+	for node := stateNode; node != nil && node.parentStateNode != nil; node = node.parentStateNode {
+		value = StateValue{
+			node.name(): value,
+		}
+	}
+
+	if value == nil {
+		return StateValue{}
+	}
+
+	return value
+}
+
+// State describes a state of a state machine, as returned by Machine.Send and Definition.Transition.
+type State struct {
+	// Value is the tree of active state nodes.
+	Value StateValue
+	// StateNode is the active atomic state node.
+	StateNode *StateNode
+	Context   Context
+	// Event is the event that lead to this state.
+	Event Event
+	// Changed reports whether the state node changed or actions were run to reach this state.
+	Changed bool
+	// Actions holds the actions that were run to reach this state, in order.
+	Actions Actions
+	// Exited holds the state nodes exited to reach this state, in the order they were exited.
+	Exited []*StateNode
+	// Entered holds the state nodes entered to reach this state, in the order they were entered.
+	Entered []*StateNode
+	// Done reports whether a final state node that is a child of the root state node has been reached.
+	Done bool
+	// Tags holds the tags of the active state nodes.
+	Tags []string
+}
+
+// Matches returns whether the state node of the state is a descendant of the state value
+// described by the selectors. See StateNode.Matches.
+func (s State) Matches(stateSelectors ...StateType) bool {
+	return s.StateNode.Matches(stateSelectors...)
+}
+
+// MatchesValue returns whether the state value of the state contains the partial hierarchy
+// described by parentValue. See StateValue.Matches.
+func (s State) MatchesValue(parentValue StateValue) bool {
+	return s.Value.Matches(parentValue)
+}
+
+// HasTag returns whether one of the active state nodes has the tag.
+func (s State) HasTag(tag string) bool {
+	for _, stateTag := range s.Tags {
+		if stateTag == tag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// macrostepRecord accumulates what happened while events were processed.
+type macrostepRecord struct {
+	actions Actions
+	exited  []*StateNode
+	entered []*StateNode
+}
+
+func newMacrostepRecord() *macrostepRecord {
+	return &macrostepRecord{
+		actions: Actions{},
+		exited:  []*StateNode{},
+		entered: []*StateNode{},
+	}
+}
+
+// newState builds the state reached by a state machine whose previous active state node was previous.
+func newState(previous *StateNode, current *StateNode, c Context, event Event, record *macrostepRecord) State {
+	return State{
+		Value:     stateValueOf(current),
+		StateNode: current,
+		Context:   c,
+		Event:     event,
+		Changed:   previous != current || len(record.actions) > 0,
+		Actions:   record.actions,
+		Exited:    record.exited,
+		Entered:   record.entered,
+		Done:      current.Final && current.parentStateNode != nil && current.parentStateNode.parentStateNode == nil,
+		Tags:      current.activeTags(),
+	}
+}
+
+// activeTags returns the tags of the state node and its ancestors, from the root state node.
+func (s *StateNode) activeTags() []string {
+	tags := make([]string, 0)
+
+	for _, stateNode := range statesToEnter(s, nil) {
+		for _, tag := range stateNode.Tags {
+			if !containsString(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+
+	return tags
+}
+
+func containsString(values []string, value string) bool {
+	for _, valueToCompare := range values {
+		if valueToCompare == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,175 @@
+package brainy_test
+
+import (
+	"testing"
+
+	"github.com/Devessier/brainy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendReturnsRichState(t *testing.T) {
+	assert := assert.New(t)
+
+	const (
+		FinishEvent brainy.EventType = "FINISH"
+		NoopEvent   brainy.EventType = "NOOP"
+
+		FinalState brainy.StateType = "final"
+	)
+
+	transitionAction := brainy.ActionFn(func(c brainy.Context, e brainy.Event) error {
+		return nil
+	})
+
+	stateMachine, err := brainy.NewMachine(brainy.StateNode{
+		Initial: CompoundState,
+
+		States: brainy.StateNodes{
+			CompoundState: &brainy.StateNode{
+				Initial: NestedAState,
+
+				Tags: []string{"busy"},
+
+				States: brainy.StateNodes{
+					NestedAState: &brainy.StateNode{
+						Tags: []string{"editing"},
+
+						On: brainy.Events{
+							GoToNestedBStateEvent: brainy.Transition{
+								Target:  NestedBState,
+								Actions: brainy.Actions{transitionAction},
+							},
+							NoopEvent: brainy.Transition{},
+						},
+					},
+
+					NestedBState: &brainy.StateNode{},
+				},
+
+				On: brainy.Events{
+					FinishEvent: FinalState,
+				},
+			},
+
+			FinalState: &brainy.StateNode{
+				Final: true,
+			},
+		},
+	})
+	assert.NoError(err)
+
+	initialState := stateMachine.State()
+	assert.True(initialState.Matches(CompoundState, NestedAState))
+	assert.Equal(brainy.StateValue{
+		CompoundState: brainy.StateValue{
+			NestedAState: nil,
+		},
+	}, initialState.Value)
+	assert.Equal("compound.nested-a", initialState.Value.String())
+	assert.Equal([]string{"busy", "editing"}, initialState.Tags)
+	assert.False(initialState.Done)
+
+	nextState, err := stateMachine.Send(NoopEvent)
+	assert.NoError(err)
+	assert.False(nextState.Changed)
+	assert.Equal(NoopEvent, nextState.Event)
+
+	nextState, err = stateMachine.Send(GoToNestedBStateEvent)
+	assert.NoError(err)
+	assert.True(nextState.Changed)
+	assert.True(nextState.Matches(CompoundState, NestedBState))
+	assert.Len(nextState.Actions, 1)
+	assert.Len(nextState.Exited, 1)
+	assert.True(nextState.Exited[0].Matches(CompoundState, NestedAState))
+	assert.Len(nextState.Entered, 1)
+	assert.True(nextState.Entered[0].Matches(CompoundState, NestedBState))
+	assert.True(nextState.HasTag("busy"))
+	assert.False(nextState.HasTag("editing"))
+
+	nextState, err = stateMachine.Send(FinishEvent)
+	assert.NoError(err)
+	assert.True(nextState.Done)
+	assert.Len(nextState.Exited, 2)
+	assert.Empty(nextState.Tags)
+	assert.Equal(nextState, stateMachine.State())
+}
+
+func TestMatchesComparesWholeSegments(t *testing.T) {
+	assert := assert.New(t)
+
+	const OnlineState brainy.StateType = "online"
+
+	stateMachine, err := brainy.NewMachine(brainy.StateNode{
+		Initial: OnlineState,
+
+		States: brainy.StateNodes{
+			OnState: &brainy.StateNode{},
+
+			OnlineState: &brainy.StateNode{},
+		},
+	})
+	assert.NoError(err)
+
+	assert.True(stateMachine.Current().Matches(OnlineState))
+	assert.False(stateMachine.Current().Matches(OnState))
+	assert.False(stateMachine.State().Matches(OnState))
+}
+
+func TestParseStateValue(t *testing.T) {
+	assert := assert.New(t)
+
+	value, err := brainy.ParseStateValue("compound.nested-a")
+	assert.NoError(err)
+	assert.Equal(brainy.StateValue{
+		CompoundState: brainy.StateValue{
+			NestedAState: nil,
+		},
+	}, value)
+
+	parallelValue, err := brainy.ParseStateValue("parallel.left.a, parallel.right")
+	assert.NoError(err)
+	assert.Equal(brainy.StateValue{
+		"parallel": brainy.StateValue{
+			"left": brainy.StateValue{
+				"a": nil,
+			},
+			"right": nil,
+		},
+	}, parallelValue)
+	assert.Equal("parallel.left.a, parallel.right", parallelValue.String())
+
+	_, err = brainy.ParseStateValue("compound..nested-a")
+	assert.ErrorIs(err, brainy.ErrInvalidStateValue)
+}
+
+func TestStateValueMatchesPartialHierarchies(t *testing.T) {
+	assert := assert.New(t)
+
+	value := brainy.StateValue{
+		"parallel": brainy.StateValue{
+			"left": brainy.StateValue{
+				"a": nil,
+			},
+			"right": brainy.StateValue{
+				"b": nil,
+			},
+		},
+	}
+
+	testCases := []struct {
+		ParentValue string
+		ShouldMatch bool
+	}{
+		{ParentValue: "parallel", ShouldMatch: true},
+		{ParentValue: "parallel.left", ShouldMatch: true},
+		{ParentValue: "parallel.left.a, parallel.right.b", ShouldMatch: true},
+		{ParentValue: "parallel.right.a", ShouldMatch: false},
+		{ParentValue: "para", ShouldMatch: false},
+	}
+
+	for _, testCase := range testCases {
+		parentValue, err := brainy.ParseStateValue(testCase.ParentValue)
+		assert.NoError(err)
+		assert.Equal(testCase.ShouldMatch, value.Matches(parentValue), testCase.ParentValue)
+	}
+}
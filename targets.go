@@ -0,0 +1,182 @@
+package brainy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Reasons why a transition target could not be resolved.
+// They are held by ErrInvalidTransitionNotImplementedWithDetails errors.
+var (
+	// ErrUnknownTargetStateNode is returned when a target references a state node that does not exist.
+	ErrUnknownTargetStateNode = errors.New("unknown target state node")
+	// ErrAmbiguousTargetStateNode is returned when a target references by `#id` an ID shared by several state nodes.
+	ErrAmbiguousTargetStateNode = errors.New("ambiguous target state node")
+	// ErrInconsistentTargets is returned when the branches of a CompoundTarget diverge into distinct states,
+	// which could only be entered together in orthogonal regions of a parallel state node.
+	ErrInconsistentTargets = errors.New("inconsistent targets")
+)
+
+const (
+	// uniqueIDTargetPrefix begins targets that reference a state node by its unique ID.
+	uniqueIDTargetPrefix = "#"
+	// childTargetPrefix begins targets that reference a descendant of the state node handling the event.
+	childTargetPrefix = "."
+)
+
+// TargetID returns a target referencing the state node whose ID is id, followed by an optional path
+// to one of its descendants.
+//
+//  TargetID("payment", "awaiting")
+//  // => "#payment.awaiting"
+func TargetID(id string, path ...StateType) StateType {
+	segments := append([]StateType{StateType(id)}, path...)
+
+	return StateType(uniqueIDTargetPrefix + joinStateTypes(segments...))
+}
+
+// AbsoluteTarget returns a target referencing a state node by its path from the root state node.
+//
+//  AbsoluteTarget(CompoundState, AtomicState)
+//  // => "#(machine).compound.atomic"
+func AbsoluteTarget(path ...StateType) StateType {
+	return TargetID(string(rootStateNodeID), path...)
+}
+
+// ChildTarget returns a target referencing a descendant of the state node that handles the event.
+//
+//  ChildTarget(AtomicState)
+//  // => ".atomic"
+func ChildTarget(path ...StateType) StateType {
+	return StateType(childTargetPrefix + joinStateTypes(path...))
+}
+
+// indexStateNodesIDs indexes the state nodes of the definition by their unique ID.
+// The root state node can always be referenced by the reserved `(machine)` ID.
+func (definition *Definition) indexStateNodesIDs() {
+	definition.stateNodesByID = map[string][]*StateNode{
+		string(rootStateNodeID): {definition.root},
+	}
+
+	var indexStateNode func(stateNode *StateNode)
+	indexStateNode = func(stateNode *StateNode) {
+		if stateNode.ID != "" {
+			definition.stateNodesByID[stateNode.ID] = append(definition.stateNodesByID[stateNode.ID], stateNode)
+		}
+
+		for _, childStateNode := range stateNode.childrenInDocumentOrder {
+			indexStateNode(childStateNode)
+		}
+	}
+
+	indexStateNode(definition.root)
+}
+
+// resolveTransitionTarget returns the state node targeted by the transition handled by stateNodeWithHandler.
+// The target of the transition must not be blank.
+//
+// Each branch of the target is resolved in one of the following ways:
+//
+// 1. `#id.path` targets are resolved from the state node whose ID is id; `#(machine)` is the root state node
+//
+// 2. `.path` targets are resolved from the state node handling the event
+//
+// 3. other targets are resolved from the parent of the state node handling the event, or from the root
+// state node if it handles the event
+//
+// The path is followed state by state. When the target has several branches, they must all lie on
+// the same path from the root state node, and the most nested targeted state node is returned.
+// The state node that is entered is the most nested initial state node of the returned state node.
+func resolveTransitionTarget(stateNodeWithHandler *StateNode, transitionToExecute Transition) (*StateNode, error) {
+	var mostNestedTargetedStateNode *StateNode
+
+	for _, branch := range transitionToExecute.Target.branches() {
+		targetedStateNode, err := resolveTargetID(stateNodeWithHandler, joinStateTypes(branch...))
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case mostNestedTargetedStateNode == nil || targetedStateNode.isDescendantOf(mostNestedTargetedStateNode):
+			mostNestedTargetedStateNode = targetedStateNode
+		case targetedStateNode == mostNestedTargetedStateNode || mostNestedTargetedStateNode.isDescendantOf(targetedStateNode):
+		default:
+			return nil, fmt.Errorf(
+				"%w: %s and %s can only be targeted together in orthogonal regions of a parallel state node, which are not supported",
+				ErrInconsistentTargets,
+				mostNestedTargetedStateNode.id,
+				targetedStateNode.id,
+			)
+		}
+	}
+
+	if mostNestedTargetedStateNode == nil {
+		return nil, fmt.Errorf("%w: target %q has no branch", ErrUnknownTargetStateNode, transitionToExecute.Target.String())
+	}
+
+	return mostNestedTargetedStateNode, nil
+}
+
+// resolveTargetID returns the state node targeted by a single branch target.
+func resolveTargetID(stateNodeWithHandler *StateNode, targetID string) (*StateNode, error) {
+	var (
+		stateNodeResolvingPoint *StateNode
+		path                    string
+	)
+
+	switch {
+	case strings.HasPrefix(targetID, uniqueIDTargetPrefix):
+		segments := strings.SplitN(strings.TrimPrefix(targetID, uniqueIDTargetPrefix), ".", 2)
+
+		stateNodes := stateNodeWithHandler.definition.stateNodesByID[segments[0]]
+		switch len(stateNodes) {
+		case 0:
+			return nil, fmt.Errorf("%w: no state node has the id %q", ErrUnknownTargetStateNode, segments[0])
+		case 1:
+			stateNodeResolvingPoint = stateNodes[0]
+		default:
+			return nil, fmt.Errorf("%w: %d state nodes have the id %q", ErrAmbiguousTargetStateNode, len(stateNodes), segments[0])
+		}
+
+		if len(segments) > 1 {
+			path = segments[1]
+		}
+	case strings.HasPrefix(targetID, childTargetPrefix):
+		stateNodeResolvingPoint = stateNodeWithHandler
+		path = strings.TrimPrefix(targetID, childTargetPrefix)
+	default:
+		// The state node from which we will resolve the target is either
+		// the root state node of the state machine or the parent state node
+		// of the one that handled the event.
+		if isParentRootStateNode := stateNodeWithHandler.parentStateNode == nil; isParentRootStateNode {
+			stateNodeResolvingPoint = stateNodeWithHandler
+		} else {
+			stateNodeResolvingPoint = stateNodeWithHandler.parentStateNode
+		}
+
+		path = targetID
+	}
+
+	return stateNodeResolvingPoint.followPath(path)
+}
+
+// followPath returns the descendant of the state node described by a dotted path of state names.
+// An empty path describes the state node itself.
+func (s *StateNode) followPath(path string) (*StateNode, error) {
+	if path == "" {
+		return s, nil
+	}
+
+	stateNode := s
+	for _, segment := range strings.Split(path, ".") {
+		childStateNode, ok := stateNode.States[StateType(segment)]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s has no child state node named %q", ErrUnknownTargetStateNode, stateNode.id, segment)
+		}
+
+		stateNode = childStateNode
+	}
+
+	return stateNode, nil
+}
@@ -0,0 +1,209 @@
+package brainy_test
+
+import (
+	"testing"
+
+	"github.com/Devessier/brainy"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	CheckoutState        brainy.StateType = "checkout"
+	PaymentState         brainy.StateType = "payment"
+	AwaitingPaymentState brainy.StateType = "awaiting_payment"
+	PaidState            brainy.StateType = "paid"
+
+	PayEvent      brainy.EventType = "PAY"
+	RetryEvent    brainy.EventType = "RETRY"
+	RestartEvent  brainy.EventType = "RESTART"
+	ConfirmEvent  brainy.EventType = "CONFIRM"
+	ProceedsEvent brainy.EventType = "PROCEEDS"
+)
+
+func newCheckoutConfig(payTarget brainy.StateType) brainy.StateNode {
+	return brainy.StateNode{
+		Initial: CheckoutState,
+
+		States: brainy.StateNodes{
+			CheckoutState: &brainy.StateNode{
+				Initial: OnState,
+
+				States: brainy.StateNodes{
+					OnState: &brainy.StateNode{
+						On: brainy.Events{
+							PayEvent: payTarget,
+						},
+					},
+				},
+
+				On: brainy.Events{
+					ProceedsEvent: brainy.ChildTarget(OnState),
+				},
+			},
+
+			PaymentState: &brainy.StateNode{
+				ID: "payment",
+
+				Initial: AwaitingPaymentState,
+
+				States: brainy.StateNodes{
+					AwaitingPaymentState: &brainy.StateNode{
+						On: brainy.Events{
+							ConfirmEvent: PaidState,
+						},
+					},
+
+					PaidState: &brainy.StateNode{},
+				},
+
+				On: brainy.Events{
+					RestartEvent: brainy.AbsoluteTarget(CheckoutState, OnState),
+					RetryEvent:   brainy.ChildTarget(AwaitingPaymentState),
+				},
+			},
+		},
+	}
+}
+
+func TestTargetsByUniqueIDAndPaths(t *testing.T) {
+	assert := assert.New(t)
+
+	stateMachine, err := brainy.NewMachine(newCheckoutConfig(brainy.TargetID("payment")))
+	assert.NoError(err)
+	assert.True(stateMachine.Current().Matches(CheckoutState, OnState))
+
+	nextState, err := stateMachine.Send(ProceedsEvent)
+	assert.NoError(err)
+	assert.True(nextState.Matches(CheckoutState, OnState))
+
+	nextState, err = stateMachine.Send(PayEvent)
+	assert.NoError(err)
+	assert.True(nextState.Matches(PaymentState, AwaitingPaymentState))
+
+	nextState, err = stateMachine.Send(ConfirmEvent)
+	assert.NoError(err)
+	assert.True(nextState.Matches(PaymentState, PaidState))
+
+	nextState, err = stateMachine.Send(RetryEvent)
+	assert.NoError(err)
+	assert.True(nextState.Matches(PaymentState, AwaitingPaymentState))
+
+	nextState, err = stateMachine.Send(RestartEvent)
+	assert.NoError(err)
+	assert.True(nextState.Matches(CheckoutState, OnState))
+
+	stateMachine, err = brainy.NewMachine(newCheckoutConfig(brainy.TargetID("payment", PaidState)))
+	assert.NoError(err)
+
+	nextState, err = stateMachine.Send(PayEvent)
+	assert.NoError(err)
+	assert.True(nextState.Matches(PaymentState, PaidState))
+}
+
+func TestValidatorReportsUnknownAndAmbiguousTargets(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := brainy.NewMachine(newCheckoutConfig(brainy.TargetID("unknown")))
+	assert.ErrorIs(err, brainy.ErrInvalidTransitionNotImplemented)
+	assert.ErrorIs(err, brainy.ErrUnknownTargetStateNode)
+
+	_, err = brainy.NewMachine(newCheckoutConfig(brainy.TargetID("payment", "unknown")))
+	assert.ErrorIs(err, brainy.ErrUnknownTargetStateNode)
+
+	ambiguousConfig := newCheckoutConfig(brainy.TargetID("payment"))
+	ambiguousConfig.States[CheckoutState].ID = "payment"
+
+	_, err = brainy.NewMachine(ambiguousConfig)
+	assert.ErrorIs(err, brainy.ErrInvalidTransitionNotImplemented)
+	assert.ErrorIs(err, brainy.ErrAmbiguousTargetStateNode)
+}
+
+func TestTargetsDoNotMatchStatesSharingAPrefix(t *testing.T) {
+	assert := assert.New(t)
+
+	const OnlineState brainy.StateType = "online"
+
+	for i := 0; i < 10; i++ {
+		stateMachine, err := brainy.NewMachine(brainy.StateNode{
+			Initial: OffState,
+
+			Order: []brainy.StateType{OnlineState, OnState, OffState},
+
+			States: brainy.StateNodes{
+				OnlineState: &brainy.StateNode{},
+
+				OnState: &brainy.StateNode{},
+
+				OffState: &brainy.StateNode{
+					On: brainy.Events{
+						OnEvent: OnState,
+					},
+				},
+			},
+		})
+		assert.NoError(err)
+
+		nextState, err := stateMachine.Send(OnEvent)
+		assert.NoError(err)
+		assert.Equal("(machine).on", nextState.StateNode.Value())
+	}
+}
+
+func TestCompoundTargetsBranches(t *testing.T) {
+	newConfig := func(target brainy.Targeter) brainy.StateNode {
+		return brainy.StateNode{
+			Initial: OnState,
+
+			States: brainy.StateNodes{
+				OnState: &brainy.StateNode{
+					On: brainy.Events{
+						PayEvent: brainy.Transition{
+							Target: target,
+						},
+					},
+				},
+
+				PaymentState: &brainy.StateNode{
+					ID: "payment",
+
+					Initial: AwaitingPaymentState,
+
+					States: brainy.StateNodes{
+						AwaitingPaymentState: &brainy.StateNode{},
+
+						PaidState: &brainy.StateNode{},
+					},
+				},
+			},
+		}
+	}
+
+	t.Run("consistent branches target the most nested state node", func(t *testing.T) {
+		assert := assert.New(t)
+
+		target := brainy.CompoundTarget{
+			brainy.TargetID("payment"):          PaidState,
+			brainy.AbsoluteTarget(PaymentState): PaidState,
+		}
+		assert.Equal("#(machine).payment.paid, #payment.paid", target.String())
+
+		stateMachine, err := brainy.NewMachine(newConfig(target))
+		assert.NoError(err)
+
+		nextState, err := stateMachine.Send(PayEvent)
+		assert.NoError(err)
+		assert.True(nextState.Matches(PaymentState, PaidState))
+	})
+
+	t.Run("diverging branches are rejected", func(t *testing.T) {
+		assert := assert.New(t)
+
+		stateMachine, err := brainy.NewMachine(newConfig(brainy.CompoundTarget{
+			PaymentState:               AwaitingPaymentState,
+			brainy.TargetID("payment"): PaidState,
+		}))
+		assert.Nil(stateMachine)
+		assert.ErrorIs(err, brainy.ErrInconsistentTargets)
+		assert.ErrorIs(err, brainy.ErrInvalidTransitionNotImplemented)
+	})
+}
@@ -0,0 +1,360 @@
+// Package testing generates the sequences of events that lead to the states of a brainy definition,
+// and runs them against state machines, so that every state and transition of a chart can be tested
+// without writing every sequence by hand.
+//
+// A Model explores a definition with Definition.Transition: only Assign and Send actions are interpreted,
+// and guards are evaluated with the sample events given to the model.
+//
+//  model := testing.NewModel(definition, testing.WithSampleEvents(SubmitEvent, validSubmit, invalidSubmit))
+//
+//  paths, err := model.ShortestPaths()
+//  for _, path := range paths {
+//      machine, _ := brainy.NewMachineFromDefinition(definition)
+//      err := model.TestPath(machine, path, testing.Assertions{
+//          "submitted": func(state brainy.State) error { ... },
+//      })
+//  }
+package testing
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Devessier/brainy"
+)
+
+// ErrUnexpectedState is returned by Model.TestPath when the state machine does not reach the state
+// expected by the path.
+var ErrUnexpectedState = errors.New("unexpected state")
+
+// ErrUnreachableState is returned by Model.SimplePathsBetween when one of the states can not be
+// reached from the initial state.
+var ErrUnreachableState = errors.New("state not reachable from the initial state")
+
+// A ModelOption configures a Model.
+type ModelOption func(*Model)
+
+// WithSampleEvents registers the events sent to explore the transitions of eventType.
+// Guards usually depend on the payload of events: one sample should be given for each case they distinguish.
+//
+// Without samples, the event type itself is sent. Events handled through descriptors
+// such as `*` or `error.*` are only explored with samples registered for the descriptor.
+func WithSampleEvents(eventType brainy.EventType, events ...brainy.Event) ModelOption {
+	return func(model *Model) {
+		model.sampleEvents[eventType] = append(model.sampleEvents[eventType], events...)
+	}
+}
+
+// WithStateSerializer sets the function that identifies the states of the model.
+// Two states with the same serialization are considered to be the same state.
+// By default, states are identified by their state value: contexts are not compared.
+func WithStateSerializer(serialize func(state brainy.State) string) ModelOption {
+	return func(model *Model) {
+		model.serializeState = serialize
+	}
+}
+
+// A Model explores the states of a definition.
+type Model struct {
+	definition     *brainy.Definition
+	sampleEvents   map[brainy.EventType][]brainy.Event
+	serializeState func(state brainy.State) string
+}
+
+// NewModel returns a model exploring the definition.
+func NewModel(definition *brainy.Definition, options ...ModelOption) *Model {
+	model := &Model{
+		definition:   definition,
+		sampleEvents: make(map[brainy.EventType][]brainy.Event),
+		serializeState: func(state brainy.State) string {
+			return state.Value.String()
+		},
+	}
+
+	for _, option := range options {
+		option(model)
+	}
+
+	return model
+}
+
+// A Segment is a step of a path: the event sent and the state it leads to.
+type Segment struct {
+	EventType brainy.EventType
+	Event     brainy.Event
+	State     brainy.State
+}
+
+// A Path is a sequence of events leading from Source, usually the initial state, to State.
+// The path from a state to itself has no segment.
+type Path struct {
+	Source   brainy.State
+	State    brainy.State
+	Segments []Segment
+}
+
+// String describes the path by the state it reaches and the events it sends, such as `on via TOGGLE`.
+func (path Path) String() string {
+	if len(path.Segments) == 0 {
+		return path.State.Value.String()
+	}
+
+	eventTypes := make([]string, 0, len(path.Segments))
+	for _, segment := range path.Segments {
+		eventTypes = append(eventTypes, string(segment.EventType))
+	}
+
+	return path.State.Value.String() + " via " + strings.Join(eventTypes, ", ")
+}
+
+// edge is a transition between two states of the model.
+type edge struct {
+	eventType brainy.EventType
+	event     brainy.Event
+	target    string
+}
+
+// stateGraph holds the states reachable from the initial state, identified by their serialization,
+// and the transitions between them.
+type stateGraph struct {
+	initial string
+	states  map[string]brainy.State
+	edges   map[string][]edge
+	// order holds the serialized states in the order they were discovered.
+	order []string
+}
+
+// explore discovers breadth-first the states reachable from the initial state.
+// Event types are tried in alphabetical order and samples in the order they were registered.
+func (model *Model) explore() (*stateGraph, error) {
+	initialState, err := model.definition.InitialState()
+	if err != nil {
+		return nil, err
+	}
+
+	graph := &stateGraph{
+		initial: model.serializeState(initialState),
+		states:  make(map[string]brainy.State),
+		edges:   make(map[string][]edge),
+	}
+	graph.states[graph.initial] = initialState
+	graph.order = append(graph.order, graph.initial)
+
+	for index := 0; index < len(graph.order); index++ {
+		source := graph.order[index]
+		state := graph.states[source]
+
+		for _, eventType := range state.NextEvents() {
+			for _, event := range model.eventsFor(eventType) {
+				nextState, err := model.definition.Transition(state, event)
+				if errors.Is(err, brainy.ErrNoTransitionCouldBeRun) {
+					continue
+				}
+				if err != nil {
+					return nil, fmt.Errorf("exploring %s from %s: %w", eventType, source, err)
+				}
+
+				target := model.serializeState(nextState)
+				graph.edges[source] = append(graph.edges[source], edge{
+					eventType: eventType,
+					event:     event,
+					target:    target,
+				})
+
+				if _, ok := graph.states[target]; !ok {
+					graph.states[target] = nextState
+					graph.order = append(graph.order, target)
+				}
+			}
+		}
+	}
+
+	return graph, nil
+}
+
+func (model *Model) eventsFor(eventType brainy.EventType) []brainy.Event {
+	if samples, ok := model.sampleEvents[eventType]; ok {
+		return samples
+	}
+
+	if strings.Contains(string(eventType), "*") {
+		return nil
+	}
+
+	return []brainy.Event{eventType}
+}
+
+// ShortestPaths returns a shortest path to each state reachable from the initial state,
+// in the order the states are discovered.
+func (model *Model) ShortestPaths() ([]Path, error) {
+	graph, err := model.explore()
+	if err != nil {
+		return nil, err
+	}
+
+	segmentsTo := map[string][]Segment{
+		graph.initial: {},
+	}
+	paths := []Path{
+		{
+			Source:   graph.states[graph.initial],
+			State:    graph.states[graph.initial],
+			Segments: []Segment{},
+		},
+	}
+
+	for _, source := range graph.order {
+		for _, transition := range graph.edges[source] {
+			if _, ok := segmentsTo[transition.target]; ok {
+				continue
+			}
+
+			segments := append(append([]Segment{}, segmentsTo[source]...), Segment{
+				EventType: transition.eventType,
+				Event:     transition.event,
+				State:     graph.states[transition.target],
+			})
+
+			segmentsTo[transition.target] = segments
+			paths = append(paths, Path{
+				Source:   graph.states[graph.initial],
+				State:    graph.states[transition.target],
+				Segments: segments,
+			})
+		}
+	}
+
+	return paths, nil
+}
+
+// SimplePaths returns every path from the initial state that does not go through the same state twice,
+// including the path to the initial state itself.
+// The number of simple paths grows quickly with the number of transitions.
+func (model *Model) SimplePaths() ([]Path, error) {
+	graph, err := model.explore()
+	if err != nil {
+		return nil, err
+	}
+
+	return graph.simplePaths(graph.initial, func(target string) bool {
+		return true
+	}), nil
+}
+
+// SimplePathsBetween returns every path from the state from to the state to that does not go through
+// the same state twice. When both states are the same, the only path returned has no segment.
+// The states are identified by their serialization, and must be reachable from the initial state.
+func (model *Model) SimplePathsBetween(from, to brainy.State) ([]Path, error) {
+	graph, err := model.explore()
+	if err != nil {
+		return nil, err
+	}
+
+	source, target := model.serializeState(from), model.serializeState(to)
+	for _, state := range []string{source, target} {
+		if _, ok := graph.states[state]; !ok {
+			return nil, fmt.Errorf("%w: %s", ErrUnreachableState, state)
+		}
+	}
+
+	return graph.simplePaths(source, func(state string) bool {
+		return state == target
+	}), nil
+}
+
+// simplePaths returns the paths from source that do not go through the same state twice
+// and lead to a state accepted by isTarget.
+func (graph *stateGraph) simplePaths(source string, isTarget func(state string) bool) []Path {
+	paths := make([]Path, 0)
+	visited := make(map[string]bool)
+
+	var visit func(state string, segments []Segment)
+	visit = func(state string, segments []Segment) {
+		visited[state] = true
+		defer delete(visited, state)
+
+		if isTarget(state) {
+			paths = append(paths, Path{
+				Source:   graph.states[source],
+				State:    graph.states[state],
+				Segments: segments,
+			})
+		}
+
+		for _, transition := range graph.edges[state] {
+			if visited[transition.target] {
+				continue
+			}
+
+			visit(transition.target, append(append([]Segment{}, segments...), Segment{
+				EventType: transition.eventType,
+				Event:     transition.event,
+				State:     graph.states[transition.target],
+			}))
+		}
+	}
+
+	visit(source, []Segment{})
+
+	return paths
+}
+
+// An Assertion checks a state reached by a state machine.
+type Assertion func(state brainy.State) error
+
+// Assertions maps state values, such as `compound` or `compound.atomic`, to the assertion run
+// each time a state matching the value is reached. See brainy.State.MatchesValue.
+type Assertions map[string]Assertion
+
+// TestPath sends the events of the path to the state machine, that must be in the source state of the path,
+// and checks that each state of the path is reached. The assertions of the states that are reached
+// are run, including the ones of the source state.
+func (model *Model) TestPath(machine *brainy.Machine, path Path, assertions Assertions) error {
+	if err := model.checkState(machine.State(), path.Source, assertions); err != nil {
+		return fmt.Errorf("source state: %w", err)
+	}
+
+	for index, segment := range path.Segments {
+		state, err := machine.Send(segment.Event)
+		if err != nil {
+			return fmt.Errorf("segment %d: sending %s: %w", index, segment.EventType, err)
+		}
+
+		if err := model.checkState(state, segment.State, assertions); err != nil {
+			return fmt.Errorf("segment %d: after %s: %w", index, segment.EventType, err)
+		}
+	}
+
+	return nil
+}
+
+func (model *Model) checkState(state brainy.State, expectedState brainy.State, assertions Assertions) error {
+	if actual, expected := model.serializeState(state), model.serializeState(expectedState); actual != expected {
+		return fmt.Errorf("%w: expected %s, got %s", ErrUnexpectedState, expected, actual)
+	}
+
+	stateValues := make([]string, 0, len(assertions))
+	for stateValue := range assertions {
+		stateValues = append(stateValues, stateValue)
+	}
+	sort.Strings(stateValues)
+
+	for _, stateValue := range stateValues {
+		parsedStateValue, err := brainy.ParseStateValue(stateValue)
+		if err != nil {
+			return err
+		}
+
+		if !state.MatchesValue(parsedStateValue) {
+			continue
+		}
+
+		if err := assertions[stateValue](state); err != nil {
+			return fmt.Errorf("assertion of %s: %w", stateValue, err)
+		}
+	}
+
+	return nil
+}
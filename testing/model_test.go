@@ -0,0 +1,222 @@
+package testing_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Devessier/brainy"
+	brainytesting "github.com/Devessier/brainy/testing"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	ClosedState brainy.StateType = "closed"
+	OpenedState brainy.StateType = "opened"
+	LockedState brainy.StateType = "locked"
+
+	OpenEvent   brainy.EventType = "OPEN"
+	CloseEvent  brainy.EventType = "CLOSE"
+	LockEvent   brainy.EventType = "LOCK"
+	UnlockEvent brainy.EventType = "UNLOCK"
+)
+
+type UnlockWithCodeEvent struct {
+	brainy.EventWithType
+	Code string
+}
+
+func newDoorDefinition(t *testing.T) *brainy.Definition {
+	definition, err := brainy.NewDefinition(brainy.StateNode{
+		Initial: ClosedState,
+
+		States: brainy.StateNodes{
+			ClosedState: &brainy.StateNode{
+				On: brainy.Events{
+					OpenEvent: OpenedState,
+					LockEvent: LockedState,
+				},
+			},
+
+			OpenedState: &brainy.StateNode{
+				On: brainy.Events{
+					CloseEvent: ClosedState,
+				},
+			},
+
+			LockedState: &brainy.StateNode{
+				On: brainy.Events{
+					UnlockEvent: brainy.Transition{
+						Cond: func(c brainy.Context, e brainy.Event) bool {
+							return e.(UnlockWithCodeEvent).Code == "1234"
+						},
+						Target: ClosedState,
+					},
+				},
+			},
+		},
+
+		Order: []brainy.StateType{ClosedState, OpenedState, LockedState},
+	})
+	assert.NoError(t, err)
+
+	return definition
+}
+
+func unlockWithCode(code string) UnlockWithCodeEvent {
+	return UnlockWithCodeEvent{
+		EventWithType: brainy.EventWithType{
+			Event: UnlockEvent,
+		},
+		Code: code,
+	}
+}
+
+func pathsDescriptions(paths []brainytesting.Path) []string {
+	descriptions := make([]string, 0, len(paths))
+	for _, path := range paths {
+		descriptions = append(descriptions, path.String())
+	}
+
+	return descriptions
+}
+
+func TestModelPaths(t *testing.T) {
+	assert := assert.New(t)
+
+	definition := newDoorDefinition(t)
+	model := brainytesting.NewModel(definition, brainytesting.WithSampleEvents(UnlockEvent, unlockWithCode("0000"), unlockWithCode("1234")))
+
+	shortestPaths, err := model.ShortestPaths()
+	assert.NoError(err)
+	assert.Equal([]string{
+		"closed",
+		"locked via LOCK",
+		"opened via OPEN",
+	}, pathsDescriptions(shortestPaths))
+
+	simplePaths, err := model.SimplePaths()
+	assert.NoError(err)
+	assert.Equal([]string{
+		"closed",
+		"locked via LOCK",
+		"opened via OPEN",
+	}, pathsDescriptions(simplePaths))
+
+	for _, path := range shortestPaths {
+		t.Run(path.String(), func(t *testing.T) {
+			machine, err := brainy.NewMachineFromDefinition(definition)
+			assert.NoError(err)
+
+			lockedAssertions := 0
+			err = model.TestPath(machine, path, brainytesting.Assertions{
+				string(LockedState): func(state brainy.State) error {
+					lockedAssertions++
+					return nil
+				},
+			})
+			assert.NoError(err)
+
+			if path.State.Matches(LockedState) {
+				assert.Equal(1, lockedAssertions)
+			}
+		})
+	}
+}
+
+func TestModelSimplePathsBetweenStates(t *testing.T) {
+	assert := assert.New(t)
+
+	definition, err := brainy.NewDefinition(brainy.StateNode{
+		Initial: ClosedState,
+
+		States: brainy.StateNodes{
+			ClosedState: &brainy.StateNode{
+				On: brainy.Events{
+					OpenEvent: OpenedState,
+					LockEvent: LockedState,
+				},
+			},
+
+			OpenedState: &brainy.StateNode{
+				On: brainy.Events{
+					LockEvent: LockedState,
+				},
+			},
+
+			LockedState: &brainy.StateNode{},
+		},
+	})
+	assert.NoError(err)
+
+	model := brainytesting.NewModel(definition)
+
+	simplePaths, err := model.SimplePaths()
+	assert.NoError(err)
+	assert.Equal([]string{
+		"closed",
+		"locked via LOCK",
+		"opened via OPEN",
+		"locked via OPEN, LOCK",
+	}, pathsDescriptions(simplePaths))
+
+	closedState, openedState, lockedState := simplePaths[0].State, simplePaths[2].State, simplePaths[1].State
+
+	pathsFromClosed, err := model.SimplePathsBetween(closedState, lockedState)
+	assert.NoError(err)
+	assert.Equal([]string{
+		"locked via LOCK",
+		"locked via OPEN, LOCK",
+	}, pathsDescriptions(pathsFromClosed))
+
+	pathsFromOpened, err := model.SimplePathsBetween(openedState, lockedState)
+	assert.NoError(err)
+	assert.Equal([]string{
+		"locked via LOCK",
+	}, pathsDescriptions(pathsFromOpened))
+	assert.True(pathsFromOpened[0].Source.Matches(OpenedState))
+
+	pathsToOpened, err := model.SimplePathsBetween(lockedState, openedState)
+	assert.NoError(err)
+	assert.Empty(pathsToOpened)
+
+	machine, err := brainy.NewMachineFromDefinition(definition)
+	assert.NoError(err)
+	_, err = machine.Send(OpenEvent)
+	assert.NoError(err)
+	assert.NoError(model.TestPath(machine, pathsFromOpened[0], nil))
+
+	_, err = model.SimplePathsBetween(brainy.State{Value: brainy.StateValue{"unknown": nil}}, lockedState)
+	assert.ErrorIs(err, brainytesting.ErrUnreachableState)
+}
+
+func TestModelReportsFailures(t *testing.T) {
+	assert := assert.New(t)
+
+	definition := newDoorDefinition(t)
+	model := brainytesting.NewModel(definition, brainytesting.WithSampleEvents(UnlockEvent, unlockWithCode("1234")))
+
+	paths, err := model.ShortestPaths()
+	assert.NoError(err)
+
+	openedPath := paths[2]
+	assert.True(openedPath.State.Matches(OpenedState))
+
+	errOpened := errors.New("door should not be opened")
+
+	machine, err := brainy.NewMachineFromDefinition(definition)
+	assert.NoError(err)
+	err = model.TestPath(machine, openedPath, brainytesting.Assertions{
+		string(OpenedState): func(state brainy.State) error {
+			return errOpened
+		},
+	})
+	assert.ErrorIs(err, errOpened)
+
+	machine, err = brainy.NewMachineFromDefinition(definition)
+	assert.NoError(err)
+	_, err = machine.Send(LockEvent)
+	assert.NoError(err)
+
+	err = model.TestPath(machine, openedPath, nil)
+	assert.ErrorIs(err, brainytesting.ErrUnexpectedState)
+}
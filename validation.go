@@ -0,0 +1,193 @@
+package brainy
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Problems with the IDs of state nodes, reported by ErrInvalidDefinition errors.
+var (
+	// ErrStateNodeIDWithDot is returned when the ID of a state node contains a dot,
+	// as dots separate the ID from the path in `#id.path` targets.
+	ErrStateNodeIDWithDot = errors.New("state node id can not contain a dot")
+	// ErrDuplicateStateNodeID is returned when several state nodes have the same ID.
+	ErrDuplicateStateNodeID = errors.New("state node id is not unique")
+)
+
+// ErrInvalidStateNode holds a problem found while validating the state node at Path.
+type ErrInvalidStateNode struct {
+	Path string
+	Err  error
+}
+
+func (err *ErrInvalidStateNode) Error() string {
+	return err.Path + ": " + err.Err.Error()
+}
+
+func (err *ErrInvalidStateNode) Unwrap() error {
+	return err.Err
+}
+
+// ErrInvalidDefinition is returned when a configuration is invalid. It holds every problem found
+// while validating the configuration, each of them as an ErrInvalidStateNode error,
+// in the document order of the state nodes.
+//
+// errors.Is and errors.As match an ErrInvalidDefinition error if they match one of its errors;
+// errors.As returns the first one.
+type ErrInvalidDefinition struct {
+	Errors []error
+}
+
+func (err *ErrInvalidDefinition) Error() string {
+	errorsAsStrings := make([]string, 0, len(err.Errors))
+	for _, validationErr := range err.Errors {
+		errorsAsStrings = append(errorsAsStrings, validationErr.Error())
+	}
+
+	return "invalid definition (" + strconv.Itoa(len(err.Errors)) + " errors): " + strings.Join(errorsAsStrings, "; ")
+}
+
+// Is returns whether one of the errors of err matches the target error.
+func (err *ErrInvalidDefinition) Is(target error) bool {
+	for _, validationErr := range err.Errors {
+		if errors.Is(validationErr, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// As finds the first error of err that matches target, and if so, sets target to it.
+func (err *ErrInvalidDefinition) As(target interface{}) bool {
+	for _, validationErr := range err.Errors {
+		if errors.As(validationErr, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// definitionValidation gathers the problems found while validating a configuration.
+type definitionValidation struct {
+	errors         []error
+	stateNodesByID map[string]*StateNode
+}
+
+func (validation *definitionValidation) report(stateNode *StateNode, err error) {
+	validation.errors = append(validation.errors, &ErrInvalidStateNode{
+		Path: stateNode.id,
+		Err:  err,
+	})
+}
+
+// validate checks the state node and all its descendants, and returns an ErrInvalidDefinition
+// error listing every problem found, or nil if the configuration is valid.
+func (s *StateNode) validate() error {
+	validation := &definitionValidation{
+		errors: make([]error, 0),
+		stateNodesByID: map[string]*StateNode{
+			string(rootStateNodeID): s,
+		},
+	}
+
+	s.collectValidationErrors(validation)
+
+	if len(validation.errors) == 0 {
+		return nil
+	}
+
+	return &ErrInvalidDefinition{
+		Errors: validation.errors,
+	}
+}
+
+// collectValidationErrors reports the problems of the state node, and then the ones of its children,
+// in document order.
+func (s *StateNode) collectValidationErrors(validation *definitionValidation) {
+	s.collectIDValidationErrors(validation)
+
+	if err := s.validateInvokes(); err != nil {
+		validation.report(s, err)
+	}
+
+	if err := s.validateChoice(); err != nil {
+		validation.report(s, err)
+	}
+
+	if !s.isAtomic() {
+		if s.Initial == NoneState {
+			validation.report(s, ErrBlankInitialStateForCompoundState)
+		} else if _, ok := s.States[s.Initial]; !ok {
+			validation.report(s, &ErrInvalidInitialState{
+				InvalidInitialState: s.Initial,
+			})
+		}
+	}
+
+	encounteredOrderedStateTypes := make(map[StateType]bool, len(s.Order))
+	for _, stateType := range s.Order {
+		if _, ok := s.States[stateType]; !ok || encounteredOrderedStateTypes[stateType] {
+			validation.report(s, &ErrInvalidStateOrder{
+				InvalidState: stateType,
+			})
+		}
+
+		encounteredOrderedStateTypes[stateType] = true
+	}
+
+	s.collectHandlersValidationErrors(validation)
+
+	for _, stateNode := range s.childrenInDocumentOrder {
+		stateNode.collectValidationErrors(validation)
+	}
+}
+
+func (s *StateNode) collectIDValidationErrors(validation *definitionValidation) {
+	if s.ID == "" {
+		return
+	}
+
+	if strings.Contains(s.ID, ".") {
+		validation.report(s, fmt.Errorf("%w: %q", ErrStateNodeIDWithDot, s.ID))
+	}
+
+	if stateNodeWithSameID, ok := validation.stateNodesByID[s.ID]; ok && stateNodeWithSameID != s {
+		validation.report(s, fmt.Errorf("%w: %q is also the id of %s", ErrDuplicateStateNodeID, s.ID, stateNodeWithSameID.id))
+		return
+	}
+
+	validation.stateNodesByID[s.ID] = s
+}
+
+// collectHandlersValidationErrors checks that the transitions of the state node can be resolved.
+func (s *StateNode) collectHandlersValidationErrors(validation *definitionValidation) {
+	for _, eventType := range s.On.sortedEventTypes() {
+		for _, transition := range s.On[eventType].transitions() {
+			if transition.Cond != nil && transition.Guard != nil {
+				validation.report(s, ErrTransitionWithCondAndGuard)
+			}
+
+			if transition.Guard != nil {
+				if err := validateGuard(transition.Guard); err != nil {
+					validation.report(s, fmt.Errorf("%w, in a transition of %s", err, eventType))
+				}
+			}
+
+			if transition.isTargetBlank() {
+				continue
+			}
+
+			if _, err := resolveTransitionTarget(s, transition); err != nil {
+				validation.report(s, &ErrInvalidTransitionNotImplementedWithDetails{
+					From:   s,
+					Target: transition.Target,
+					Err:    err,
+				})
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package brainy_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Devessier/brainy"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidationReportsEveryError(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := brainy.NewDefinition(brainy.StateNode{
+		Initial: CheckoutState,
+
+		Order: []brainy.StateType{CheckoutState, PaymentState},
+
+		States: brainy.StateNodes{
+			CheckoutState: &brainy.StateNode{
+				ID: "checkout.v2",
+
+				States: brainy.StateNodes{
+					OnState: &brainy.StateNode{},
+				},
+			},
+
+			PaymentState: &brainy.StateNode{
+				ID: "shared",
+
+				Initial: AwaitingPaymentState,
+
+				States: brainy.StateNodes{
+					AwaitingPaymentState: &brainy.StateNode{
+						ID: "shared",
+
+						On: brainy.Events{
+							ConfirmEvent: brainy.StateType("unknown"),
+						},
+					},
+				},
+			},
+		},
+
+		On: brainy.Events{
+			RestartEvent: brainy.StateType("unknown"),
+		},
+	})
+
+	var definitionErr *brainy.ErrInvalidDefinition
+	assert.True(errors.As(err, &definitionErr))
+
+	expectedErrors := []struct {
+		Path string
+		Err  error
+	}{
+		{
+			Path: "(machine)",
+			Err:  brainy.ErrInvalidTransitionNotImplemented,
+		},
+		{
+			Path: "(machine).checkout",
+			Err:  brainy.ErrStateNodeIDWithDot,
+		},
+		{
+			Path: "(machine).checkout",
+			Err:  brainy.ErrBlankInitialStateForCompoundState,
+		},
+		{
+			Path: "(machine).payment.awaiting_payment",
+			Err:  brainy.ErrDuplicateStateNodeID,
+		},
+		{
+			Path: "(machine).payment.awaiting_payment",
+			Err:  brainy.ErrUnknownTargetStateNode,
+		},
+	}
+
+	if !assert.Len(definitionErr.Errors, len(expectedErrors)) {
+		return
+	}
+
+	for index, expectedError := range expectedErrors {
+		var stateNodeErr *brainy.ErrInvalidStateNode
+		assert.True(errors.As(definitionErr.Errors[index], &stateNodeErr))
+		assert.Equal(expectedError.Path, stateNodeErr.Path)
+		assert.ErrorIs(stateNodeErr, expectedError.Err)
+		assert.ErrorIs(err, expectedError.Err)
+	}
+}